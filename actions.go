@@ -0,0 +1,567 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	csrfLifetime time.Duration = 12 * time.Hour
+)
+
+// errRowsChanged is returned when the rows matching a filter have changed
+// since the deletion was confirmed.
+var errRowsChanged = errors.New("matching commands changed since confirmation")
+
+// AuditEntry records a change made by an admin.
+type AuditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	User      string    `json:"user"`
+	Client    string    `json:"client"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+	Rows      int64     `json:"rows"`
+	Result    string    `json:"result"`
+}
+
+// csrfKey signs the tokens embedded in forms. Tokens therefore do not survive
+// a restart, after which forms must be reloaded.
+var csrfKey = func() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	return key
+}()
+
+var crossOrigin = http.NewCrossOriginProtection()
+
+// changedAt is when commands were last changed by an admin, in nanoseconds
+// since the epoch. Deletions need not change the newest command, so this is
+// also accounted for when validating cached copies of the listing. As changes
+// made before a restart are unknown, it starts at startup.
+var changedAt atomic.Int64
+
+var auditMu sync.Mutex
+
+func init() {
+	changedAt.Store(processStart.UnixNano())
+}
+
+var deleteTemplate = `  <p><a href="{{.Listing}}">Back to listing</a></p>
+    <h3>Delete {{.Count}} commands matching the current filter?</h3>
+    <p>This cannot be undone.</p>
+    <form method="post" action="{{base}}/admin/delete">
+      <input type="hidden" name="filter" value="{{.Filter}}">
+      <input type="hidden" name="count" value="{{.Count}}">
+      <input type="hidden" name="csrf_token" value="{{.Token}}">
+      <input type="submit" value="Delete {{.Count}} commands">
+    </form>
+`
+
+var renameTemplate = `  <p><a href="{{base}}/">Back to listing</a></p>
+    <h3>Rename a host across all commands</h3>
+    <form method="post" action="{{base}}/admin/rename">
+      <label>host <select name="from">
+{{range .HostNames}}        <option value="{{.}}"{{if eq . $.Selected}} selected{{end}}>{{.}}</option>
+{{end}}      </select></label>
+      <label>new name <input type="text" name="to" required></label>
+      <input type="hidden" name="csrf_token" value="{{.Token}}">
+      <input type="submit" value="Rename">
+    </form>
+`
+
+// requestAdmin returns the user a request was authenticated as, and whether
+// that user is permitted to change commands.
+func requestAdmin(r *http.Request, s *Settings) (string, bool) {
+	user := RequestIdentity(r)
+
+	return user, user != "" && slices.Contains(s.Admins, user)
+}
+
+func csrfToken(user string, issued time.Time) string {
+	mac := hmac.New(sha256.New, csrfKey)
+
+	fmt.Fprintf(mac, "%s\x00%d", user, issued.Unix())
+
+	return fmt.Sprintf("%d.%s", issued.Unix(), hex.EncodeToString(mac.Sum(nil)))
+}
+
+// validCSRFToken reports whether the token was issued to the user by this
+// process, within the last csrfLifetime.
+func validCSRFToken(token, user string) bool {
+	issued, _, found := strings.Cut(token, ".")
+
+	seconds, err := strconv.ParseInt(issued, 10, 64)
+	if !found || err != nil {
+		return false
+	}
+
+	t := time.Unix(seconds, 0)
+	if time.Since(t) > csrfLifetime {
+		return false
+	}
+
+	return hmac.Equal([]byte(token), []byte(csrfToken(user, t)))
+}
+
+// AdminOnly restricts the handler to admins. State-changing requests must
+// also come from the same origin and carry a valid CSRF token.
+func AdminOnly(handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		s := settings()
+
+		user, ok := requestAdmin(r, s)
+		if !ok {
+			Forbidden(w)
+
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			err := crossOrigin.Check(r)
+			if err == nil && !validCSRFToken(r.PostFormValue("csrf_token"), user) {
+				err = errors.New("invalid CSRF token")
+			}
+
+			if err != nil {
+				if s.Verbose {
+					fmt.Printf("%s | ADMIN: Rejected request from %s (%s): %v\n",
+						time.Now().Format(logDate),
+						user,
+						r.RemoteAddr,
+						err)
+				}
+
+				Forbidden(w)
+
+				return
+			}
+		}
+
+		handle(w, r, p)
+	}
+}
+
+func newAuditEntry(r *http.Request, action, target string) *AuditEntry {
+	return &AuditEntry{
+		Timestamp: time.Now(),
+		User:      RequestIdentity(r),
+		Client:    r.RemoteAddr,
+		Action:    action,
+		Target:    target,
+	}
+}
+
+// WriteAudit logs the entry and, if --audit-log is set, appends it to that
+// file as a line of JSON.
+func WriteAudit(entry *AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if auditLog != "" {
+		auditMu.Lock()
+		defer auditMu.Unlock()
+
+		file, err := os.OpenFile(auditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+
+		_, err = file.Write(append(data, '\n'))
+
+		err = errors.Join(err, file.Close())
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("%s | AUDIT: %s\n", entry.Timestamp.Format(logDate), data)
+
+	return nil
+}
+
+// recordResult records the outcome of an attempted change in the audit log.
+// By then the change has either been made or not, so failing to record it is
+// only logged.
+func recordResult(entry *AuditEntry, result string) {
+	entry.Timestamp = time.Now()
+	entry.Result = result
+
+	err := WriteAudit(entry)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// applyChange runs the statement in a transaction, which is only committed
+// once the attempted change has been recorded in the audit log. Whether it
+// was then committed is recorded in a second entry. If expected is not
+// negative, the change is rolled back unless exactly that many rows are
+// affected.
+func applyChange(database *Database, entry *AuditEntry, expected int64, statement string, args ...any) (int64, error) {
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return 0, err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	ctx := context.Background()
+
+	tx, err := connection.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, statement, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	entry.Rows = tag.RowsAffected()
+
+	switch {
+	case expected >= 0 && entry.Rows != expected:
+		return 0, errRowsChanged
+	case entry.Rows == 0:
+		return 0, nil
+	}
+
+	entry.Result = "attempted"
+
+	err = WriteAudit(entry)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		recordResult(entry, "failed")
+
+		return 0, err
+	}
+
+	changedAt.Store(time.Now().UnixNano())
+
+	recordResult(entry, "committed")
+
+	return entry.Rows, nil
+}
+
+// listingQuery returns the query of the listing the request was referred
+// from, if any, so that admins can be returned to it after making a change.
+func listingQuery(r *http.Request) string {
+	referer, err := url.Parse(r.Referer())
+	if err != nil || referer.Host != r.Host || referer.Path != basePath+"/" {
+		return ""
+	}
+
+	return parseQuery(referer.Query()).Query().Encode()
+}
+
+// filterQuery returns only those parameters which determine which commands
+// match, omitting those which only affect how they are displayed.
+func filterQuery(parameters *Parameters) url.Values {
+	query := parameters.Query()
+
+	for _, key := range []string{"count", "sort_by", "sort_order", "bucket", "live"} {
+		query.Del(key)
+	}
+
+	return query
+}
+
+func CountMatching(database *Database, parameters *Parameters, s *Settings) (int64, error) {
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return 0, err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	conditions := filterConditions(database.Table, parameters, s)
+
+	var count int64
+
+	err = connection.QueryRow(context.Background(),
+		fmt.Sprintf("SELECT COUNT(*) FROM %s%s", database.Table, conditions.Where()),
+		conditions.Args()...).Scan(&count)
+
+	return count, err
+}
+
+func GetHostNames(database *Database) ([]string, error) {
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	return getHostNames(connection, database.Table)
+}
+
+func serveAdminPage(w http.ResponseWriter, title, body string, data any) error {
+	t, err := template.New("admin").Funcs(template.FuncMap{
+		"base": templateBase,
+	}).Parse(body)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+
+	securityHeaders(w)
+
+	_, err = io.WriteString(w, GeneratePageHeader(title))
+	if err != nil {
+		return err
+	}
+
+	err = t.Execute(w, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, GeneratePageFooter())
+
+	return err
+}
+
+func ServeDeleteCommand(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		id, err := strconv.ParseInt(p.ByName("id"), 10, 64)
+		if err != nil {
+			NotFound(w)
+
+			return
+		}
+
+		entry := newAuditEntry(r, "delete", fmt.Sprintf("id=%d", id))
+
+		rows, err := applyChange(database, entry, -1,
+			fmt.Sprintf("DELETE FROM %s WHERE id = $1", database.Table), id)
+		switch {
+		case err != nil:
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		case rows == 0:
+			NotFound(w)
+
+			return
+		}
+
+		// Return to the listing the command was opened from.
+		listing := basePath + "/"
+
+		query, err := url.ParseQuery(r.PostFormValue("listing"))
+		if err == nil && len(query) > 0 {
+			listing += "?" + parseQuery(query).Query().Encode()
+		}
+
+		http.Redirect(w, r, listing, http.StatusSeeOther)
+	}
+}
+
+// ServeDeleteConfirmation shows how many commands match the filter, and asks
+// for confirmation before deleting them.
+func ServeDeleteConfirmation(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		s := settings()
+
+		user, _ := requestAdmin(r, s)
+
+		// The filter is posted back as re-encoded here, which truncates times
+		// to the minute, so the count shown must be for exactly that filter.
+		parameters := parseQuery(filterQuery(parseParameters(r)))
+
+		// Deleting every command is never what was meant.
+		if filterConditions(database.Table, parameters, s).Where() == "" {
+			BadRequest(w)
+
+			return
+		}
+
+		count, err := CountMatching(database, parameters, s)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		filter := filterQuery(parameters).Encode()
+
+		err = serveAdminPage(w, "Delete Commands", deleteTemplate, struct {
+			Listing string
+			Filter  string
+			Count   int64
+			Token   string
+		}{
+			Listing: basePath + "/?" + filter,
+			Filter:  filter,
+			Count:   count,
+			Token:   csrfToken(user, time.Now()),
+		})
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// ServeDeleteMatching deletes the commands matching the confirmed filter, as
+// long as their number has not changed since it was confirmed. Otherwise, the
+// confirmation is shown again.
+func ServeDeleteMatching(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		query, err := url.ParseQuery(r.PostFormValue("filter"))
+		if err != nil {
+			BadRequest(w)
+
+			return
+		}
+
+		expected, err := strconv.ParseInt(r.PostFormValue("count"), 10, 64)
+		if err != nil || expected < 0 {
+			BadRequest(w)
+
+			return
+		}
+
+		parameters := parseQuery(query)
+
+		conditions := filterConditions(database.Table, parameters, settings())
+		if conditions.Where() == "" {
+			BadRequest(w)
+
+			return
+		}
+
+		filter := filterQuery(parameters).Encode()
+
+		entry := newAuditEntry(r, "delete_matching", filter)
+
+		_, err = applyChange(database, entry, expected,
+			fmt.Sprintf("DELETE FROM %s%s", database.Table, conditions.Where()),
+			conditions.Args()...)
+		switch {
+		case errors.Is(err, errRowsChanged):
+			http.Redirect(w, r, basePath+"/admin/delete?"+filter, http.StatusSeeOther)
+
+			return
+		case err != nil:
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		http.Redirect(w, r, basePath+"/?"+filter, http.StatusSeeOther)
+	}
+}
+
+func ServeRenameForm(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		user, _ := requestAdmin(r, settings())
+
+		hostNames, err := GetHostNames(database)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		err = serveAdminPage(w, "Rename Host", renameTemplate, struct {
+			HostNames []string
+			Selected  string
+			Token     string
+		}{
+			HostNames: hostNames,
+			Selected:  r.URL.Query().Get("host_name"),
+			Token:     csrfToken(user, time.Now()),
+		})
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// ServeRenameHost renames a host across every command it has run, such as
+// when a host was misconfigured or has been replaced.
+func ServeRenameHost(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		from := r.PostFormValue("from")
+		to := strings.TrimSpace(r.PostFormValue("to"))
+
+		if from == "" || to == "" || from == to {
+			BadRequest(w)
+
+			return
+		}
+
+		entry := newAuditEntry(r, "rename_host", fmt.Sprintf("%s -> %s", from, to))
+
+		rows, err := applyChange(database, entry, -1,
+			fmt.Sprintf("UPDATE %s SET hostname = $1 WHERE hostname = $2", database.Table), to, from)
+		switch {
+		case err != nil:
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		case rows == 0:
+			NotFound(w)
+
+			return
+		}
+
+		http.Redirect(w, r, basePath+"/?"+url.Values{"host_name": {to}}.Encode(), http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,287 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	healthTimeout time.Duration = 5 * time.Second
+)
+
+var processStart = time.Now()
+
+type Health struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Module struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+}
+
+type BuildInfo struct {
+	Version      string            `json:"version"`
+	GoVersion    string            `json:"go_version"`
+	Path         string            `json:"path"`
+	Settings     map[string]string `json:"settings"`
+	Dependencies []Module          `json:"dependencies"`
+}
+
+type RuntimeStats struct {
+	UptimeSeconds   float64 `json:"uptime_seconds"`
+	Goroutines      int     `json:"goroutines"`
+	GOMAXPROCS      int     `json:"gomaxprocs"`
+	CPUs            int     `json:"cpus"`
+	HeapAllocBytes  uint64  `json:"heap_alloc_bytes"`
+	HeapInuseBytes  uint64  `json:"heap_inuse_bytes"`
+	HeapObjects     uint64  `json:"heap_objects"`
+	TotalAllocBytes uint64  `json:"total_alloc_bytes"`
+	SysBytes        uint64  `json:"sys_bytes"`
+	GCCycles        uint32  `json:"gc_cycles"`
+	GCPauseSeconds  float64 `json:"gc_pause_seconds"`
+}
+
+// checkDatabase reports whether a connection to the database can be opened
+// and used.
+func checkDatabase(database *Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+	defer cancel()
+
+	connection, err := pgx.Connect(ctx, database.Url)
+	if err != nil {
+		return err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	return connection.Ping(ctx)
+}
+
+func GetBuildInfo() *BuildInfo {
+	info := &BuildInfo{
+		Version:   ReleaseVersion,
+		GoVersion: runtime.Version(),
+		Settings:  map[string]string{},
+	}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.Path = build.Path
+
+	for _, setting := range build.Settings {
+		info.Settings[setting.Key] = setting.Value
+	}
+
+	for _, dependency := range build.Deps {
+		info.Dependencies = append(info.Dependencies, Module{
+			Path:    dependency.Path,
+			Version: dependency.Version,
+		})
+	}
+
+	return info
+}
+
+func GetRuntimeStats() *RuntimeStats {
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+
+	return &RuntimeStats{
+		UptimeSeconds:   time.Since(processStart).Seconds(),
+		Goroutines:      runtime.NumGoroutine(),
+		GOMAXPROCS:      runtime.GOMAXPROCS(0),
+		CPUs:            runtime.NumCPU(),
+		HeapAllocBytes:  memory.HeapAlloc,
+		HeapInuseBytes:  memory.HeapInuse,
+		HeapObjects:     memory.HeapObjects,
+		TotalAllocBytes: memory.TotalAlloc,
+		SysBytes:        memory.Sys,
+		GCCycles:        memory.NumGC,
+		GCPauseSeconds:  time.Duration(memory.PauseTotalNs).Seconds(),
+	}
+}
+
+func ServeHealth(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		health := &Health{Status: "ok"}
+		status := http.StatusOK
+
+		err := checkDatabase(database)
+		if err != nil {
+			fmt.Println(err)
+
+			health = &Health{Status: "unavailable", Error: "database unreachable"}
+			status = http.StatusServiceUnavailable
+		}
+
+		data, err := json.Marshal(health)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+
+		securityHeaders(w)
+
+		w.WriteHeader(status)
+
+		w.Write(append(data, '\n'))
+	}
+}
+
+func ServeBuildInfo() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		serveJSON(w, GetBuildInfo())
+	}
+}
+
+func ServeRuntimeStats() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		serveJSON(w, GetRuntimeStats())
+	}
+}
+
+// ServeProfile dispatches to the net/http/pprof handlers. Named profiles,
+// including the goroutine leak profile when built with
+// GOEXPERIMENT=goroutineleakprofile, are served by pprof.Index.
+func ServeProfile() httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		switch p.ByName("profile") {
+		case "/cmdline":
+			pprof.Cmdline(w, r)
+		case "/profile":
+			pprof.Profile(w, r)
+		case "/symbol":
+			pprof.Symbol(w, r)
+		case "/trace":
+			pprof.Trace(w, r)
+		default:
+			pprof.Index(w, r)
+		}
+	}
+}
+
+// hashCredential allows credentials of differing lengths to be compared in
+// constant time.
+func hashCredential(credential string) []byte {
+	hash := sha256.Sum256([]byte(credential))
+
+	return hash[:]
+}
+
+// BasicAuth requires the configured admin user and password, if any, on every
+// request.
+func BasicAuth(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := settings()
+
+		if s.AdminUser == "" {
+			handler.ServeHTTP(w, r)
+
+			return
+		}
+
+		expectedUser := hashCredential(s.AdminUser)
+		expectedPass := hashCredential(s.AdminPass)
+
+		u, p, ok := r.BasicAuth()
+
+		userMatches := subtle.ConstantTimeCompare(hashCredential(u), expectedUser)
+		passMatches := subtle.ConstantTimeCompare(hashCredential(p), expectedPass)
+
+		if !ok || userMatches&passMatches != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="commands", charset="UTF-8"`)
+			w.Header().Add("Content-Type", "text/plain")
+
+			securityHeaders(w)
+
+			w.WriteHeader(http.StatusUnauthorized)
+
+			w.Write([]byte("401 Unauthorized\n"))
+
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// loopbackAddress reports whether the address (host:port) can only be reached
+// from the local host.
+func loopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// NewAdminServer serves diagnostics, which should not be exposed alongside
+// the UI, on --admin-bind. If TLS is configured for the UI, the same
+// configuration is used, so that admin credentials are never sent in the
+// clear.
+func NewAdminServer(database *Database, tlsConfig *tls.Config) *http.Server {
+	mux := httprouter.New()
+
+	mux.PanicHandler = ServerErrorHandler()
+
+	mux.GET("/health", ServeHealth(database))
+
+	mux.GET("/metrics", ServeMetrics(database))
+
+	mux.GET("/version", ServeVersion())
+
+	mux.GET("/buildinfo", ServeBuildInfo())
+
+	mux.GET("/runtime", ServeRuntimeStats())
+
+	mux.GET("/debug/pprof/*profile", ServeProfile())
+
+	mux.POST("/debug/pprof/*profile", ServeProfile())
+
+	return &http.Server{
+		Addr:         adminBind,
+		Handler:      Compress(BasicAuth(mux)),
+		TLSConfig:    tlsConfig,
+		IdleTimeout:  10 * time.Minute,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Minute,
+	}
+}
@@ -0,0 +1,737 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultAlertWindow time.Duration = 24 * time.Hour
+	webhookTimeout     time.Duration = 10 * time.Second
+)
+
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+
+	d.Duration, err = time.ParseDuration(value)
+
+	return err
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+type Webhook struct {
+	Name   string `json:"name"`
+	Url    string `json:"url"`
+	Format string `json:"format"`
+}
+
+type AlertRule struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Host      string   `json:"host"`
+	Command   string   `json:"command"`
+	Threshold float64  `json:"threshold"`
+	MinRuns   int      `json:"min_runs"`
+	Window    Duration `json:"window"`
+	Webhooks  []string `json:"webhooks"`
+}
+
+type Silence struct {
+	Rule    string    `json:"rule"`
+	Host    string    `json:"host"`
+	Command string    `json:"command"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Comment string    `json:"comment"`
+}
+
+type AlertConfig struct {
+	Webhooks []Webhook   `json:"webhooks"`
+	Rules    []AlertRule `json:"rules"`
+	Silences []Silence   `json:"silences"`
+}
+
+type Alert struct {
+	Rule      *AlertRule
+	Host      string
+	Command   string
+	Value     float64
+	Message   string
+	StartedAt time.Time
+
+	// notified is set once every webhook has been sent the firing
+	// notification, firing and resolved holding those which have been sent
+	// each so far, so that failed deliveries are retried to those alone.
+	notified bool
+	firing   map[string]bool
+	resolved map[string]bool
+}
+
+type Notification struct {
+	Status    string    `json:"status"`
+	Rule      string    `json:"rule"`
+	Type      string    `json:"type"`
+	Host      string    `json:"host,omitempty"`
+	Command   string    `json:"command,omitempty"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Window    Duration  `json:"window"`
+	Message   string    `json:"message"`
+	StartedAt time.Time `json:"started_at"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type Alerter struct {
+	database *Database
+	client   *http.Client
+
+	mu     sync.Mutex
+	alerts map[string]*Alert
+}
+
+// globToLike converts a shell-style glob into a LIKE pattern.
+func globToLike(glob string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`, `?`, `_`)
+
+	return replacer.Replace(glob)
+}
+
+// globToRegexp converts a shell-style glob into an anchored regular expression.
+func globToRegexp(glob string) *regexp.Regexp {
+	replacer := strings.NewReplacer(`\*`, `.*`, `\?`, `.`)
+
+	return regexp.MustCompile("^" + replacer.Replace(regexp.QuoteMeta(glob)) + "$")
+}
+
+func matchGlob(glob, value string) bool {
+	return glob == "" || globToRegexp(glob).MatchString(value)
+}
+
+func LoadAlertConfig(path string) (*AlertConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &AlertConfig{}
+
+	err = json.Unmarshal(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	webhooks := make(map[string]bool, len(config.Webhooks))
+	for _, webhook := range config.Webhooks {
+		switch webhook.Format {
+		case "", "json", "slack":
+		default:
+			return nil, fmt.Errorf("webhook %q: invalid format %q", webhook.Name, webhook.Format)
+		}
+
+		webhooks[webhook.Name] = true
+	}
+
+	names := make(map[string]bool, len(config.Rules))
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+
+		if rule.Name == "" || names[rule.Name] {
+			return nil, fmt.Errorf("rule %d: name must be unique and non-empty", i)
+		}
+		names[rule.Name] = true
+
+		switch rule.Type {
+		case "failure", "anomaly":
+		case "count", "rate":
+			if rule.Threshold <= 0 {
+				return nil, fmt.Errorf("rule %q: threshold must be positive", rule.Name)
+			}
+		default:
+			return nil, fmt.Errorf("rule %q: invalid type %q", rule.Name, rule.Type)
+		}
+
+		if rule.Window.Duration <= 0 {
+			rule.Window.Duration = defaultAlertWindow
+		}
+
+		for _, name := range rule.Webhooks {
+			if !webhooks[name] {
+				return nil, fmt.Errorf("rule %q: unknown webhook %q", rule.Name, name)
+			}
+		}
+	}
+
+	for i, silence := range config.Silences {
+		if !silence.End.After(silence.Start) {
+			return nil, fmt.Errorf("silence %d: end must be after start", i)
+		}
+	}
+
+	return config, nil
+}
+
+func NewAlerter(database *Database) *Alerter {
+	return &Alerter{
+		database: database,
+		client:   &http.Client{Timeout: webhookTimeout},
+		alerts:   make(map[string]*Alert),
+	}
+}
+
+func ruleConditions(rule *AlertRule) *Conditions {
+	c := &Conditions{}
+
+	c.Add("starttime >= $%d", time.Now().Add(-rule.Window.Duration))
+
+	if rule.Host != "" {
+		c.Add("hostname like $%d", globToLike(rule.Host))
+	}
+
+	if rule.Command != "" {
+		c.Add("commandname like $%d", globToLike(rule.Command))
+	}
+
+	return c
+}
+
+func evaluateFailureRule(connection *pgx.Conn, tableName string, rule *AlertRule) ([]*Alert, error) {
+	conditions := ruleConditions(rule)
+
+	statement := fmt.Sprintf(`SELECT DISTINCT ON (hostname, commandname) %s FROM %s%s
+ORDER BY hostname, commandname, starttime DESC`, recordColumns, tableName, conditions.Where())
+
+	rows, err := connection.Query(context.Background(), statement, conditions.Args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := scanRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []*Alert
+
+	for _, record := range records {
+		if record.ExitCode == 0 {
+			continue
+		}
+
+		alerts = append(alerts, &Alert{
+			Rule:    rule,
+			Host:    record.HostName,
+			Command: record.CommandName,
+			Value:   float64(record.ExitCode),
+			Message: fmt.Sprintf("%s on %s exited with code %d at %s",
+				record.CommandName,
+				record.HostName,
+				record.ExitCode,
+				record.StopTime.Format(logDate)),
+		})
+	}
+
+	return alerts, nil
+}
+
+func evaluateCountRule(connection *pgx.Conn, tableName string, rule *AlertRule) ([]*Alert, error) {
+	conditions := ruleConditions(rule)
+	conditions.Add("exitcode <> $%d", 0)
+
+	statement := fmt.Sprintf(`SELECT hostname, COUNT(*) FROM %s%s
+GROUP BY hostname`, tableName, conditions.Where())
+
+	rows, err := connection.Query(context.Background(), statement, conditions.Args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []*Alert
+
+	var hostName string
+	var failures int
+	_, err = pgx.ForEachRow(rows, []any{&hostName, &failures}, func() error {
+		if float64(failures) <= rule.Threshold {
+			return nil
+		}
+
+		alerts = append(alerts, &Alert{
+			Rule:  rule,
+			Host:  hostName,
+			Value: float64(failures),
+			Message: fmt.Sprintf("%d failures on %s in the last %v (threshold %v)",
+				failures,
+				hostName,
+				rule.Window,
+				rule.Threshold),
+		})
+
+		return nil
+	})
+
+	return alerts, err
+}
+
+func evaluateRateRule(connection *pgx.Conn, tableName string, rule *AlertRule) ([]*Alert, error) {
+	conditions := ruleConditions(rule)
+
+	statement := fmt.Sprintf(`SELECT commandname, COUNT(*), SUM(CASE WHEN exitcode <> 0 THEN 1 ELSE 0 END) FROM %s%s
+GROUP BY commandname`, tableName, conditions.Where())
+
+	rows, err := connection.Query(context.Background(), statement, conditions.Args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []*Alert
+
+	var commandName string
+	var runs, failures int
+	_, err = pgx.ForEachRow(rows, []any{&commandName, &runs, &failures}, func() error {
+		if runs == 0 || runs < rule.MinRuns {
+			return nil
+		}
+
+		rate := float64(failures) / float64(runs)
+		if rate*100 <= rule.Threshold {
+			return nil
+		}
+
+		alerts = append(alerts, &Alert{
+			Rule:    rule,
+			Command: commandName,
+			Value:   rate * 100,
+			Message: fmt.Sprintf("failure rate for %s is %.1f%% over the last %v (%d of %d runs, threshold %v%%)",
+				commandName,
+				rate*100,
+				rule.Window,
+				failures,
+				runs,
+				rule.Threshold),
+		})
+
+		return nil
+	})
+
+	return alerts, err
+}
+
+func evaluateAnomalyRule(connection *pgx.Conn, tableName string, rule *AlertRule, s *Settings) ([]*Alert, error) {
+	conditions := ruleConditions(rule)
+	conditions.Append(anomalyCondition(conditions, tableName, s))
+
+	statement := fmt.Sprintf(`SELECT hostname, commandname, COUNT(*) FROM %s%s
+GROUP BY hostname, commandname`, tableName, conditions.Where())
+
+	rows, err := connection.Query(context.Background(), statement, conditions.Args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []*Alert
+
+	var hostName, commandName string
+	var anomalies int
+	_, err = pgx.ForEachRow(rows, []any{&hostName, &commandName, &anomalies}, func() error {
+		if float64(anomalies) <= rule.Threshold {
+			return nil
+		}
+
+		alerts = append(alerts, &Alert{
+			Rule:    rule,
+			Host:    hostName,
+			Command: commandName,
+			Value:   float64(anomalies),
+			Message: fmt.Sprintf("%d runs of %s on %s in the last %v took far longer or shorter than usual",
+				anomalies,
+				commandName,
+				hostName,
+				rule.Window),
+		})
+
+		return nil
+	})
+
+	return alerts, err
+}
+
+func (a *Alerter) evaluateRule(connection *pgx.Conn, rule *AlertRule, s *Settings) ([]*Alert, error) {
+	switch rule.Type {
+	case "failure":
+		return evaluateFailureRule(connection, a.database.Table, rule)
+	case "count":
+		return evaluateCountRule(connection, a.database.Table, rule)
+	case "rate":
+		return evaluateRateRule(connection, a.database.Table, rule)
+	case "anomaly":
+		return evaluateAnomalyRule(connection, a.database.Table, rule, s)
+	}
+
+	return nil, fmt.Errorf("rule %q: invalid type %q", rule.Name, rule.Type)
+}
+
+func silenced(config *AlertConfig, alert *Alert, now time.Time) bool {
+	for _, silence := range config.Silences {
+		if now.Before(silence.Start) || now.After(silence.End) {
+			continue
+		}
+
+		if matchGlob(silence.Rule, alert.Rule.Name) &&
+			matchGlob(silence.Host, alert.Host) &&
+			matchGlob(silence.Command, alert.Command) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func alertKey(alert *Alert) string {
+	return alert.Rule.Name + "\x00" + alert.Host + "\x00" + alert.Command
+}
+
+// pendingNotification is a notification which has yet to be delivered to
+// the given webhooks for the alert with the given key.
+type pendingNotification struct {
+	key          string
+	alert        *Alert
+	notification *Notification
+	webhooks     []Webhook
+}
+
+// ruleWebhooks returns the webhooks to which the rule's notifications are
+// sent, which is every webhook unless the rule names some, less those in
+// skip.
+func ruleWebhooks(config *AlertConfig, rule *AlertRule, skip map[string]bool) []Webhook {
+	var webhooks []Webhook
+
+	for _, webhook := range config.Webhooks {
+		if len(rule.Webhooks) > 0 && !slices.Contains(rule.Webhooks, webhook.Name) {
+			continue
+		}
+
+		if skip[webhook.Name] {
+			continue
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks
+}
+
+// evaluateRules runs each rule once, returning the alerts found for each rule
+// which could be evaluated.
+func (a *Alerter) evaluateRules(s *Settings) (map[string][]*Alert, []error) {
+	connection, err := openDatabase(a.database.Url)
+	if err != nil {
+		return nil, []error{err}
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	results := make(map[string][]*Alert, len(s.Alerts.Rules))
+
+	var errs []error
+
+	for i := range s.Alerts.Rules {
+		rule := &s.Alerts.Rules[i]
+
+		alerts, err := a.evaluateRule(connection, rule, s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %q: %w", rule.Name, err))
+
+			continue
+		}
+
+		results[rule.Name] = alerts
+	}
+
+	return results, errs
+}
+
+// update records which alerts are firing, returning the notifications to
+// send for those which have started firing or have resolved. Rules missing
+// from results, having failed to evaluate, are left as they were.
+func (a *Alerter) update(config *AlertConfig, results map[string][]*Alert, now time.Time) []*pendingNotification {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if config == nil {
+		clear(a.alerts)
+
+		return nil
+	}
+
+	// Alerts for rules removed on reload can no longer be resolved.
+	for key, alert := range a.alerts {
+		if !slices.ContainsFunc(config.Rules, func(rule AlertRule) bool { return rule.Name == alert.Rule.Name }) {
+			delete(a.alerts, key)
+		}
+	}
+
+	var pending []*pendingNotification
+
+	for _, rule := range config.Rules {
+		alerts, ok := results[rule.Name]
+		if !ok {
+			continue
+		}
+
+		firing := make(map[string]bool, len(alerts))
+
+		for _, alert := range alerts {
+			key := alertKey(alert)
+			firing[key] = true
+
+			existing, ok := a.alerts[key]
+			if ok {
+				existing.Value = alert.Value
+				existing.Message = alert.Message
+				alert = existing
+			} else {
+				alert.StartedAt = now
+				a.alerts[key] = alert
+			}
+
+			if alert.notified || silenced(config, alert, now) {
+				continue
+			}
+
+			pending = append(pending, &pendingNotification{
+				key:          key,
+				alert:        alert,
+				notification: newNotification("firing", alert, now),
+				webhooks:     ruleWebhooks(config, alert.Rule, alert.firing),
+			})
+		}
+
+		for key, alert := range a.alerts {
+			if alert.Rule.Name != rule.Name || firing[key] {
+				continue
+			}
+
+			// Only webhooks which were told the alert was firing are told
+			// that it has resolved.
+			if !alert.notified && len(alert.firing) == 0 {
+				delete(a.alerts, key)
+
+				continue
+			}
+
+			var webhooks []Webhook
+			for _, webhook := range ruleWebhooks(config, alert.Rule, alert.resolved) {
+				if alert.firing[webhook.Name] {
+					webhooks = append(webhooks, webhook)
+				}
+			}
+
+			pending = append(pending, &pendingNotification{
+				key:          key,
+				alert:        alert,
+				notification: newNotification("resolved", alert, now),
+				webhooks:     webhooks,
+			})
+		}
+	}
+
+	return pending
+}
+
+// delivered records the webhooks to which a notification was successfully
+// sent, so that it is not sent to them again. Those it failed to reach are
+// retried on the next evaluation.
+func (a *Alerter) delivered(p *pendingNotification, webhooks []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.alerts[p.key] != p.alert {
+		return
+	}
+
+	complete := len(webhooks) == len(p.webhooks)
+
+	switch p.notification.Status {
+	case "firing":
+		if p.alert.firing == nil {
+			p.alert.firing = make(map[string]bool, len(webhooks))
+		}
+
+		for _, name := range webhooks {
+			p.alert.firing[name] = true
+		}
+
+		p.alert.notified = complete
+	case "resolved":
+		if complete {
+			delete(a.alerts, p.key)
+
+			return
+		}
+
+		if p.alert.resolved == nil {
+			p.alert.resolved = make(map[string]bool, len(webhooks))
+		}
+
+		for _, name := range webhooks {
+			p.alert.resolved[name] = true
+		}
+	}
+}
+
+// Evaluate runs every rule once, sending notifications for alerts which have
+// started firing or have resolved since the previous evaluation. The
+// alerter's state is only locked while being updated, not while querying the
+// database or sending notifications.
+func (a *Alerter) Evaluate(s *Settings) error {
+	var results map[string][]*Alert
+
+	var errs []error
+
+	if s.Alerts != nil {
+		results, errs = a.evaluateRules(s)
+	}
+
+	for _, p := range a.update(s.Alerts, results, time.Now()) {
+		delivered, err := a.notify(s, p.webhooks, p.notification)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		a.delivered(p, delivered)
+	}
+
+	return errors.Join(errs...)
+}
+
+func newNotification(status string, alert *Alert, now time.Time) *Notification {
+	return &Notification{
+		Status:    status,
+		Rule:      alert.Rule.Name,
+		Type:      alert.Rule.Type,
+		Host:      alert.Host,
+		Command:   alert.Command,
+		Value:     alert.Value,
+		Threshold: alert.Rule.Threshold,
+		Window:    alert.Rule.Window,
+		Message:   alert.Message,
+		StartedAt: alert.StartedAt,
+		Timestamp: now,
+	}
+}
+
+// notify sends the notification to each of the webhooks, returning the names
+// of those to which it was delivered.
+func (a *Alerter) notify(s *Settings, webhooks []Webhook, notification *Notification) ([]string, error) {
+	if s.Verbose {
+		fmt.Printf("%s | ALERT: [%s] %s: %s\n",
+			notification.Timestamp.Format(logDate),
+			strings.ToUpper(notification.Status),
+			notification.Rule,
+			notification.Message)
+	}
+
+	delivered := make([]string, 0, len(webhooks))
+
+	var errs []error
+
+	for _, webhook := range webhooks {
+		err := a.send(webhook, notification)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhook %q: %w", webhook.Name, err))
+
+			continue
+		}
+
+		delivered = append(delivered, webhook.Name)
+	}
+
+	return delivered, errors.Join(errs...)
+}
+
+func (a *Alerter) send(webhook Webhook, notification *Notification) error {
+	var payload any = notification
+
+	if webhook.Format == "slack" {
+		payload = map[string]string{
+			"text": fmt.Sprintf("[%s] %s: %s",
+				strings.ToUpper(notification.Status),
+				notification.Rule,
+				notification.Message),
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	response, err := a.client.Post(webhook.Url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %s", response.Status)
+	}
+
+	return nil
+}
+
+// Run evaluates the alert rules at --alert-interval, picking up any changes
+// to either on reload.
+func (a *Alerter) Run() {
+	interval := settings().AlertInterval
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s := settings()
+
+		err := a.Evaluate(s)
+		if err != nil {
+			fmt.Printf("%s | ALERT: %v\n", time.Now().Format(logDate), err)
+		}
+
+		if s.AlertInterval != interval {
+			interval = s.AlertInterval
+
+			ticker.Reset(interval)
+		}
+
+		<-ticker.C
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGlobToLike(t *testing.T) {
+	tests := []struct {
+		glob     string
+		expected string
+	}{
+		{glob: "", expected: ""},
+		{glob: "backup", expected: "backup"},
+		{glob: "backup-*", expected: "backup-%"},
+		{glob: "web?", expected: "web_"},
+		{glob: "*.example.com", expected: "%.example.com"},
+		{glob: "100%", expected: `100\%`},
+		{glob: "db_backup", expected: `db\_backup`},
+		{glob: "db_*", expected: `db\_%`},
+		{glob: "50%_?", expected: `50\%\__`},
+		{glob: `C:\temp`, expected: `C:\\temp`},
+		{glob: `\%`, expected: `\\\%`},
+	}
+
+	for _, test := range tests {
+		like := globToLike(test.glob)
+		if like != test.expected {
+			t.Errorf("globToLike(%q) = %q, expected %q", test.glob, like, test.expected)
+		}
+	}
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob    string
+		value   string
+		matches bool
+	}{
+		{glob: "backup", value: "backup", matches: true},
+		{glob: "backup", value: "backups", matches: false},
+		{glob: "backup", value: "nightly-backup", matches: false},
+		{glob: "backup-*", value: "backup-", matches: true},
+		{glob: "backup-*", value: "backup-nightly", matches: true},
+		{glob: "web?", value: "web1", matches: true},
+		{glob: "web?", value: "web", matches: false},
+		{glob: "web?", value: "web10", matches: false},
+		{glob: "*.example.com", value: "web.example.com", matches: true},
+		{glob: "*.example.com", value: "web-example.com", matches: false},
+		{glob: "100%", value: "100%", matches: true},
+		{glob: "100%", value: "1000", matches: false},
+		{glob: "db_backup", value: "db_backup", matches: true},
+		{glob: "db_backup", value: "dbxbackup", matches: false},
+		{glob: "a+b", value: "a+b", matches: true},
+		{glob: "a+b", value: "aab", matches: false},
+		{glob: "(x)", value: "(x)", matches: true},
+	}
+
+	for _, test := range tests {
+		matches := globToRegexp(test.glob).MatchString(test.value)
+		if matches != test.matches {
+			t.Errorf("globToRegexp(%q).MatchString(%q) = %t, expected %t", test.glob, test.value, matches, test.matches)
+		}
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		glob    string
+		value   string
+		matches bool
+	}{
+		{glob: "", value: "anything", matches: true},
+		{glob: "", value: "", matches: true},
+		{glob: "*", value: "", matches: true},
+		{glob: "?", value: "", matches: false},
+	}
+
+	for _, test := range tests {
+		matches := matchGlob(test.glob, test.value)
+		if matches != test.matches {
+			t.Errorf("matchGlob(%q, %q) = %t, expected %t", test.glob, test.value, matches, test.matches)
+		}
+	}
+}
+
+func TestAlerterUpdate(t *testing.T) {
+	now := time.Date(2026, time.January, 14, 10, 0, 0, 0, time.UTC)
+
+	config := &AlertConfig{
+		Webhooks: []Webhook{{Name: "a"}, {Name: "b"}},
+		Rules: []AlertRule{
+			{Name: "r1", Type: "failure"},
+			{Name: "r2", Type: "failure", Webhooks: []string{"b"}},
+		},
+		Silences: []Silence{
+			{Rule: "r1", Host: "quiet*", Start: now.Add(-time.Hour), End: now.Add(time.Hour)},
+		},
+	}
+
+	type step struct {
+		// firing lists the hosts each rule fires for, with rules which are
+		// absent having failed to evaluate.
+		firing   map[string][]string
+		failing  []string
+		expected []string
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "notifications are sent once when firing and once when resolved",
+			steps: []step{
+				{firing: map[string][]string{"r1": {"web1"}}, expected: []string{"firing r1/web1 -> a,b"}},
+				{firing: map[string][]string{"r1": {"web1"}}},
+				{firing: map[string][]string{"r1": {}}, expected: []string{"resolved r1/web1 -> a,b"}},
+				{firing: map[string][]string{"r1": {}}},
+			},
+		},
+		{
+			name: "alerts are keyed by rule and host",
+			steps: []step{
+				{
+					firing:   map[string][]string{"r1": {"web1", "web2"}, "r2": {"web1"}},
+					expected: []string{"firing r1/web1 -> a,b", "firing r1/web2 -> a,b", "firing r2/web1 -> b"},
+				},
+				{
+					firing:   map[string][]string{"r1": {"web2"}, "r2": {"web1"}},
+					expected: []string{"resolved r1/web1 -> a,b"},
+				},
+			},
+		},
+		{
+			name: "rules which fail to evaluate are left as they were",
+			steps: []step{
+				{firing: map[string][]string{"r1": {"web1"}}, expected: []string{"firing r1/web1 -> a,b"}},
+				{firing: map[string][]string{}},
+				{firing: map[string][]string{"r1": {"web1"}}},
+				{firing: map[string][]string{"r1": {}}, expected: []string{"resolved r1/web1 -> a,b"}},
+			},
+		},
+		{
+			name: "silenced alerts are neither notified nor resolved",
+			steps: []step{
+				{firing: map[string][]string{"r1": {"quiet1"}}},
+				{firing: map[string][]string{"r1": {}}},
+				{firing: map[string][]string{"r1": {"quiet1"}, "r2": {"quiet1"}}, expected: []string{"firing r2/quiet1 -> b"}},
+			},
+		},
+		{
+			name: "failed deliveries are retried to the failed webhooks alone",
+			steps: []step{
+				{firing: map[string][]string{"r1": {"web1"}}, failing: []string{"a"}, expected: []string{"firing r1/web1 -> a,b"}},
+				{firing: map[string][]string{"r1": {"web1"}}, failing: []string{"a"}, expected: []string{"firing r1/web1 -> a"}},
+				{firing: map[string][]string{"r1": {"web1"}}, expected: []string{"firing r1/web1 -> a"}},
+				{firing: map[string][]string{"r1": {"web1"}}},
+				{firing: map[string][]string{"r1": {}}, expected: []string{"resolved r1/web1 -> a,b"}},
+			},
+		},
+		{
+			name: "resolved notifications are only sent to webhooks told of the alert",
+			steps: []step{
+				{firing: map[string][]string{"r1": {"web1"}}, failing: []string{"a"}, expected: []string{"firing r1/web1 -> a,b"}},
+				{firing: map[string][]string{"r1": {}}, expected: []string{"resolved r1/web1 -> b"}},
+				{firing: map[string][]string{"r1": {}}},
+			},
+		},
+		{
+			name: "alerts which reached no webhook resolve silently",
+			steps: []step{
+				{firing: map[string][]string{"r1": {"web1"}}, failing: []string{"a", "b"}, expected: []string{"firing r1/web1 -> a,b"}},
+				{firing: map[string][]string{"r1": {}}},
+			},
+		},
+		{
+			name: "failed resolved notifications are retried to the failed webhooks alone",
+			steps: []step{
+				{firing: map[string][]string{"r1": {"web1"}}, expected: []string{"firing r1/web1 -> a,b"}},
+				{firing: map[string][]string{"r1": {}}, failing: []string{"b"}, expected: []string{"resolved r1/web1 -> a,b"}},
+				{firing: map[string][]string{"r1": {}}, expected: []string{"resolved r1/web1 -> b"}},
+				{firing: map[string][]string{"r1": {}}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		a := NewAlerter(nil)
+
+		for i, step := range test.steps {
+			results := make(map[string][]*Alert, len(step.firing))
+
+			for name, hosts := range step.firing {
+				index := slices.IndexFunc(config.Rules, func(rule AlertRule) bool { return rule.Name == name })
+
+				results[name] = []*Alert{}
+
+				for _, host := range hosts {
+					results[name] = append(results[name], &Alert{Rule: &config.Rules[index], Host: host})
+				}
+			}
+
+			var sent []string
+
+			for _, p := range a.update(config, results, now) {
+				var names, delivered []string
+
+				for _, webhook := range p.webhooks {
+					names = append(names, webhook.Name)
+
+					if !slices.Contains(step.failing, webhook.Name) {
+						delivered = append(delivered, webhook.Name)
+					}
+				}
+
+				a.delivered(p, delivered)
+
+				sent = append(sent, fmt.Sprintf("%s %s/%s -> %s",
+					p.notification.Status, p.notification.Rule, p.notification.Host, strings.Join(names, ",")))
+			}
+
+			slices.Sort(sent)
+
+			if !slices.Equal(sent, step.expected) {
+				t.Errorf("%s: step %d sent %q, expected %q", test.name, i, sent, step.expected)
+			}
+		}
+	}
+}
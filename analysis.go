@@ -0,0 +1,303 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	flakyMinimumRuns     int           = 5
+	defaultOverlapsRange time.Duration = 7 * 24 * time.Hour
+)
+
+type Flaky struct {
+	HostName     string  `json:"host_name"`
+	CommandGroup string  `json:"command_group"`
+	Runs         int     `json:"runs"`
+	Failures     int     `json:"failures"`
+	Transitions  int     `json:"transitions"`
+	Score        float64 `json:"score"`
+}
+
+type Overlap struct {
+	HostName       string  `json:"host_name"`
+	CommandGroup   string  `json:"command_group"`
+	First          Record  `json:"first"`
+	Second         Record  `json:"second"`
+	OverlapSeconds float64 `json:"overlap_seconds"`
+}
+
+type Analysis struct {
+	Flaky        []Flaky   `json:"flaky"`
+	Overlaps     []Overlap `json:"overlaps"`
+	OverlapsFrom time.Time `json:"overlaps_from"`
+	OverlapsTo   time.Time `json:"overlaps_to"`
+}
+
+var analysisTemplate = `    <h3>Flaky commands</h3>
+    <p>Commands which alternate between success and failure, ranked by the fraction of consecutive runs with a different outcome.</p>
+    <table>
+      <thead>
+        <tr>
+          <th>host_name</th><th>command_group</th><th>runs</th><th>failures</th><th>transitions</th><th>score</th>
+        </tr>
+      </thead>
+      <tbody>
+{{range .Flaky}}        <tr>
+          <td><a href="{{base}}/?host_name={{.HostName}}">{{.HostName}}</a></td>
+          <td><a href="{{base}}/history?host_name={{.HostName}}&command_group={{.CommandGroup}}">{{.CommandGroup}}</a></td>
+          <td>{{.Runs}}</td>
+          <td>{{.Failures}}</td>
+          <td>{{.Transitions}}</td>
+          <td>{{printf "%.2f" .Score}}</td>
+        </tr>
+{{end}}      </tbody>
+    </table>
+    <h3>Overlapping runs</h3>
+    <p>Runs of the same command on the same host which started before the previous run had stopped, from {{formatTime .OverlapsFrom}} to {{formatTime .OverlapsTo}}.</p>
+    <table>
+      <thead>
+        <tr>
+          <th>host_name</th><th>command_group</th><th>first</th><th>first_start_time</th><th>second</th><th>second_start_time</th><th>overlap</th>
+        </tr>
+      </thead>
+      <tbody>
+{{range .Overlaps}}        <tr>
+          <td><a href="{{base}}/?host_name={{.HostName}}">{{.HostName}}</a></td>
+          <td><a href="{{base}}/history?host_name={{.HostName}}&command_group={{.CommandGroup}}">{{.CommandGroup}}</a></td>
+          <td><a href="{{base}}/commands/{{.First.ID}}">{{.First.ID}}</a></td>
+          <td>{{formatTime .First.StartTime}}</td>
+          <td><a href="{{base}}/commands/{{.Second.ID}}">{{.Second.ID}}</a></td>
+          <td>{{formatTime .Second.StartTime}}</td>
+          <td>{{seconds .OverlapSeconds}}</td>
+        </tr>
+{{end}}      </tbody>
+    </table>
+`
+
+// getFlaky counts the number of times consecutive runs of each command group
+// on each host changed outcome, ignoring commands which never failed or
+// never succeeded.
+func getFlaky(connection *pgx.Conn, tableName string, parameters *Parameters, s *Settings) ([]Flaky, error) {
+	conditions := filterConditions(tableName, parameters, s)
+
+	statement := fmt.Sprintf(`SELECT host_name, command_group, COUNT(*), SUM(failed)::bigint,
+SUM(CASE WHEN failed <> previous THEN 1 ELSE 0 END)::bigint
+FROM (SELECT hostname AS host_name,
+%[1]s AS command_group,
+CASE WHEN exitcode <> 0 THEN 1 ELSE 0 END AS failed,
+LAG(CASE WHEN exitcode <> 0 THEN 1 ELSE 0 END) OVER (PARTITION BY hostname, %[1]s ORDER BY starttime, id) AS previous
+FROM %[2]s%[3]s) runs
+GROUP BY 1, 2
+HAVING COUNT(*) >= %[4]d AND SUM(failed) > 0 AND SUM(failed) < COUNT(*)
+ORDER BY SUM(CASE WHEN failed <> previous THEN 1 ELSE 0 END)::float8 / (COUNT(*) - 1) DESC, 1, 2
+LIMIT %[5]d`,
+		s.CommandGroup,
+		tableName,
+		conditions.Where(),
+		flakyMinimumRuns,
+		parameters.CommandCount)
+
+	rows, err := connection.Query(context.Background(), statement, conditions.Args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Flaky, error) {
+		var f Flaky
+
+		err := row.Scan(&f.HostName, &f.CommandGroup, &f.Runs, &f.Failures, &f.Transitions)
+
+		f.Score = float64(f.Transitions) / float64(f.Runs-1)
+
+		return f, err
+	})
+}
+
+// getOverlaps finds pairs of runs of the same command group on the same host
+// where the second started before the first had stopped.
+func getOverlaps(connection *pgx.Conn, tableName string, parameters *Parameters, s *Settings) ([]Overlap, error) {
+	conditions := filterConditions(tableName, parameters, s)
+
+	statement := fmt.Sprintf(`WITH runs AS (SELECT %[1]s, %[2]s AS command_group FROM %[3]s%[4]s)
+SELECT a.hostname, a.command_group,
+a.id, a.starttime, a.stoptime, a.commandname, a.exitcode,
+b.id, b.starttime, b.stoptime, b.commandname, b.exitcode,
+EXTRACT(EPOCH FROM (LEAST(a.stoptime, b.stoptime) - b.starttime))::float8
+FROM runs a
+JOIN runs b ON b.hostname = a.hostname AND b.command_group = a.command_group
+AND b.starttime < a.stoptime
+AND (b.starttime > a.starttime OR b.starttime = a.starttime AND b.id > a.id)
+ORDER BY b.starttime DESC, b.id DESC
+LIMIT %[5]d`,
+		recordColumns,
+		s.CommandGroup,
+		tableName,
+		conditions.Where(),
+		parameters.CommandCount)
+
+	rows, err := connection.Query(context.Background(), statement, conditions.Args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Overlap, error) {
+		var o Overlap
+
+		err := row.Scan(&o.HostName, &o.CommandGroup,
+			&o.First.ID, &o.First.StartTime, &o.First.StopTime, &o.First.CommandName, &o.First.ExitCode,
+			&o.Second.ID, &o.Second.StartTime, &o.Second.StopTime, &o.Second.CommandName, &o.Second.ExitCode,
+			&o.OverlapSeconds)
+
+		o.First.HostName = o.HostName
+		o.Second.HostName = o.HostName
+
+		return o, err
+	})
+}
+
+func GetAnalysis(database *Database, parameters *Parameters, s *Settings) (*Analysis, []string, error) {
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	analysis := &Analysis{}
+
+	analysis.Flaky, err = getFlaky(connection, database.Table, parameters, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Finding overlaps joins each run against the others of its command group,
+	// so unlike flaky commands, they are only sought within a bounded range.
+	bounded := *parameters
+
+	if bounded.To.IsZero() {
+		bounded.To = time.Now()
+	}
+
+	if bounded.From.IsZero() || !bounded.From.Before(bounded.To) {
+		bounded.From = bounded.To.Add(-defaultOverlapsRange)
+	}
+
+	analysis.OverlapsFrom = bounded.From
+	analysis.OverlapsTo = bounded.To
+
+	analysis.Overlaps, err = getOverlaps(connection, database.Table, &bounded, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hostNames, err := getHostNames(connection, database.Table)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return analysis, hostNames, nil
+}
+
+func ConstructAnalysisPage(w io.Writer, parameters *Parameters, analysis *Analysis, hostNames []string) error {
+	t, err := template.New("analysis").Funcs(template.FuncMap{
+		"base": templateBase,
+		"formatTime": func(t time.Time) string {
+			return t.Format(time.DateTime)
+		},
+		"seconds": func(seconds float64) time.Duration {
+			return time.Duration(seconds * float64(time.Second)).Round(time.Millisecond)
+		},
+	}).Parse(analysisTemplate)
+	if err != nil {
+		return err
+	}
+
+	var header strings.Builder
+
+	header.WriteString(GeneratePageHeader("Flaky and Overlapping Runs"))
+	header.WriteString(generateBackLink())
+	header.WriteString(generateFilterForm("/analysis", parameters, hostNames))
+
+	_, err = io.WriteString(w, header.String())
+	if err != nil {
+		return err
+	}
+
+	err = t.Execute(w, analysis)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, GeneratePageFooter())
+
+	return err
+}
+
+func ServeAnalysis(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		startTime := time.Now()
+
+		s := settings()
+
+		parameters := parseParameters(r)
+
+		analysis, hostNames, err := GetAnalysis(database, parameters, s)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		w.Header().Add("Content-Type", "text/html")
+
+		securityHeaders(w)
+
+		err = ConstructAnalysisPage(w, parameters, analysis, hostNames)
+		if err != nil {
+			fmt.Println(err)
+
+			return
+		}
+
+		fmt.Printf("Constructed analysis page for %d flaky commands and %d overlapping runs in %v.\n",
+			len(analysis.Flaky),
+			len(analysis.Overlaps),
+			time.Since(startTime))
+	}
+}
+
+func ServeAnalysisJSON(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		s := settings()
+
+		analysis, _, err := GetAnalysis(database, parseParameters(r), s)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		serveJSON(w, analysis)
+	}
+}
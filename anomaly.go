@@ -0,0 +1,289 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	baselineMinimumRuns   int           = 5
+	baselineRefresh       time.Duration = time.Minute
+	anomalyMinimumSeconds float64       = 1
+)
+
+type Baseline struct {
+	HostName     string  `json:"host_name"`
+	CommandGroup string  `json:"command_group"`
+	Runs         int     `json:"runs"`
+	P50          float64 `json:"p50_seconds"`
+	P95          float64 `json:"p95_seconds"`
+	Interval     float64 `json:"interval_seconds"`
+	Anomalies    int     `json:"anomalies"`
+}
+
+// baselineQuery computes the median and 95th percentile durations, along
+// with the mean interval between runs, of each command group on each host
+// since the time bound to placeholder.
+func baselineQuery(tableName, group, placeholder string) string {
+	return fmt.Sprintf(`SELECT hostname AS host_name,
+%[2]s AS command_group,
+COUNT(*) AS runs,
+percentile_cont(0.5) WITHIN GROUP (ORDER BY %[4]s)::float8 AS p50,
+percentile_cont(0.95) WITHIN GROUP (ORDER BY %[4]s)::float8 AS p95,
+(EXTRACT(EPOCH FROM (MAX(starttime) - MIN(starttime))) / GREATEST(COUNT(*) - 1, 1))::float8 AS run_interval
+FROM %[1]s
+WHERE starttime >= %[3]s
+GROUP BY 1, 2`, tableName, group, placeholder, durationSeconds)
+}
+
+// anomalyExpression is true for runs of a command whose duration is more than
+// --anomaly-factor times longer or shorter than the median of its baseline b,
+// ignoring differences of less than a second and baselines of too few runs.
+func anomalyExpression(factor float64) string {
+	return fmt.Sprintf(`b.runs >= %[1]d AND (%[2]s > b.p50 * %[3]g AND %[2]s - b.p50 > %[4]g
+OR %[2]s < b.p50 / %[3]g AND b.p50 - %[2]s > %[4]g)`,
+		baselineMinimumRuns,
+		durationSeconds,
+		factor,
+		anomalyMinimumSeconds)
+}
+
+// baselineSet holds the baselines computed at one time, for one
+// configuration, along with their columns as arrays for joining against.
+type baselineSet struct {
+	generation uint64
+	computed   time.Time
+	since      time.Time
+	baselines  []Baseline
+	hosts      []string
+	groups     []string
+	runs       []int64
+	p50s       []float64
+}
+
+// currentBaselines are the most recently computed baselines, which are
+// expensive to compute, so are shared between requests until refreshed.
+var currentBaselines atomic.Pointer[baselineSet]
+
+// cachedBaselines returns the most recently computed baselines, as long as
+// they were computed for the given configuration.
+func cachedBaselines(s *Settings) *baselineSet {
+	b := currentBaselines.Load()
+	if b == nil || b.generation != s.Generation {
+		return nil
+	}
+
+	return b
+}
+
+// baselinesComputed returns the time at which the baselines that anomalies
+// are currently compared against were computed. Without cached baselines,
+// anomalies are compared against the window up to now, so this is the start
+// of the current refresh interval instead.
+func baselinesComputed(s *Settings) time.Time {
+	if b := cachedBaselines(s); b != nil {
+		return b.computed
+	}
+
+	return time.Now().Truncate(baselineRefresh)
+}
+
+// anomalyCondition binds its arguments to c, and returns an expression which
+// is true for anomalous runs. Runs are compared against the cached baselines
+// where available, and otherwise against baselines computed as part of the
+// query.
+func anomalyCondition(c *Conditions, tableName string, s *Settings) string {
+	b := cachedBaselines(s)
+	if b == nil {
+		start := c.Bind("$%d", baselineStart(s))
+
+		return fmt.Sprintf(`id IN (SELECT id FROM %[1]s
+JOIN (%[2]s) b ON b.host_name = hostname AND b.command_group = %[3]s
+WHERE starttime >= %[4]s AND %[5]s)`,
+			tableName,
+			baselineQuery(tableName, s.CommandGroup, start),
+			s.CommandGroup,
+			start,
+			anomalyExpression(s.AnomalyFactor))
+	}
+
+	return fmt.Sprintf(`(starttime >= %[1]s AND EXISTS (SELECT 1
+FROM unnest(%[2]s::text[], %[3]s::text[], %[4]s::bigint[], %[5]s::float8[]) AS b(host_name, command_group, runs, p50)
+WHERE b.host_name = hostname AND b.command_group = %[6]s AND %[7]s))`,
+		c.Bind("$%d", b.since),
+		c.Bind("$%d", b.hosts),
+		c.Bind("$%d", b.groups),
+		c.Bind("$%d", b.runs),
+		c.Bind("$%d", b.p50s),
+		s.CommandGroup,
+		anomalyExpression(s.AnomalyFactor))
+}
+
+func baselineStart(s *Settings) time.Time {
+	return time.Now().Add(-s.BaselineWindow)
+}
+
+func getBaselines(connection *pgx.Conn, tableName string, since time.Time, s *Settings) ([]Baseline, error) {
+	statement := fmt.Sprintf(`SELECT b.host_name, b.command_group, b.runs, b.p50, b.p95, b.run_interval,
+SUM(CASE WHEN %[4]s THEN 1 ELSE 0 END)::bigint
+FROM (%[2]s) b
+JOIN %[1]s ON b.host_name = hostname AND b.command_group = %[3]s AND starttime >= $1
+GROUP BY 1, 2, 3, 4, 5, 6
+ORDER BY 1, 2`,
+		tableName,
+		baselineQuery(tableName, s.CommandGroup, "$1"),
+		s.CommandGroup,
+		anomalyExpression(s.AnomalyFactor))
+
+	rows, err := connection.Query(context.Background(), statement, since)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Baseline, error) {
+		var b Baseline
+
+		err := row.Scan(&b.HostName, &b.CommandGroup, &b.Runs, &b.P50, &b.P95, &b.Interval, &b.Anomalies)
+
+		return b, err
+	})
+}
+
+func GetBaselines(database *Database, s *Settings) ([]Baseline, error) {
+	if b := cachedBaselines(s); b != nil {
+		return b.baselines, nil
+	}
+
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	return getBaselines(connection, database.Table, baselineStart(s), s)
+}
+
+// refreshBaselines computes the baselines for the given configuration, and
+// caches them for use in place of those computed as part of each query.
+func refreshBaselines(database *Database, s *Settings) error {
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	b := &baselineSet{
+		generation: s.Generation,
+		computed:   time.Now(),
+	}
+
+	b.since = b.computed.Add(-s.BaselineWindow)
+
+	b.baselines, err = getBaselines(connection, database.Table, b.since, s)
+	if err != nil {
+		return err
+	}
+
+	for _, baseline := range b.baselines {
+		b.hosts = append(b.hosts, baseline.HostName)
+		b.groups = append(b.groups, baseline.CommandGroup)
+		b.runs = append(b.runs, int64(baseline.Runs))
+		b.p50s = append(b.p50s, baseline.P50)
+	}
+
+	currentBaselines.Store(b)
+
+	return nil
+}
+
+// RefreshBaselines recomputes the baselines every baselineRefresh, so that
+// anomalies can be found without computing them again for every query. Until
+// they are recomputed after a reload, queries compute their own.
+func RefreshBaselines(database *Database) {
+	ticker := time.NewTicker(baselineRefresh)
+	defer ticker.Stop()
+
+	for {
+		err := refreshBaselines(database, settings())
+		if err != nil {
+			fmt.Printf("%s | BASELINES: %v\n", time.Now().Format(logDate), err)
+		}
+
+		<-ticker.C
+	}
+}
+
+func BaselineMetrics(baselines []Baseline) []Metric {
+	p50 := Metric{
+		Name: "commands_baseline_p50_seconds",
+		Help: "Median duration of each command group on each host within the baseline window.",
+		Type: "gauge",
+	}
+
+	p95 := Metric{
+		Name: "commands_baseline_p95_seconds",
+		Help: "95th percentile duration of each command group on each host within the baseline window.",
+		Type: "gauge",
+	}
+
+	interval := Metric{
+		Name: "commands_baseline_interval_seconds",
+		Help: "Mean interval between runs of each command group on each host within the baseline window.",
+		Type: "gauge",
+	}
+
+	anomalies := Metric{
+		Name: "commands_anomalous_runs",
+		Help: "Number of runs of each command group on each host within the baseline window with an anomalous duration.",
+		Type: "gauge",
+	}
+
+	for _, b := range baselines {
+		labels := []Label{
+			{Name: "host", Value: b.HostName},
+			{Name: "command_group", Value: b.CommandGroup},
+		}
+
+		p50.Samples = append(p50.Samples, Sample{Labels: labels, Value: b.P50})
+		p95.Samples = append(p95.Samples, Sample{Labels: labels, Value: b.P95})
+		interval.Samples = append(interval.Samples, Sample{Labels: labels, Value: b.Interval})
+		anomalies.Samples = append(anomalies.Samples, Sample{Labels: labels, Value: float64(b.Anomalies)})
+	}
+
+	return []Metric{p50, p95, interval, anomalies}
+}
+
+func ServeBaselinesJSON(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		baselines, err := GetBaselines(database, settings())
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		serveJSON(w, baselines)
+	}
+}
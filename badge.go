@@ -0,0 +1,226 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	badgeMaxAge         int           = 60
+	badgeCharacterWidth float64       = 6.5
+	badgePadding        float64       = 10
+	defaultBadgeWindow  time.Duration = 7 * 24 * time.Hour
+)
+
+const (
+	badgeGreen  string = "#4c1"
+	badgeYellow string = "#dfb317"
+	badgeRed    string = "#e05d44"
+	badgeGrey   string = "#9f9f9f"
+)
+
+type Badge struct {
+	Label   string
+	Message string
+	Color   string
+}
+
+func badgeConditions(host, command string) *Conditions {
+	c := &Conditions{}
+
+	if host != "" {
+		c.Add("hostname like $%d", globToLike(host))
+	}
+
+	if command != "" {
+		c.Add("commandname like $%d", globToLike(command))
+	}
+
+	return c
+}
+
+func getStatusBadge(connection *pgx.Conn, tableName, host, command string) (*Badge, error) {
+	conditions := badgeConditions(host, command)
+
+	statement := fmt.Sprintf(`SELECT %s FROM %s%s
+ORDER BY starttime DESC
+LIMIT 1`, recordColumns, tableName, conditions.Where())
+
+	var r Record
+	err := connection.QueryRow(context.Background(), statement, conditions.Args()...).
+		Scan(&r.ID, &r.StartTime, &r.StopTime, &r.HostName, &r.CommandName, &r.ExitCode)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return &Badge{Message: "unknown", Color: badgeGrey}, nil
+	case err != nil:
+		return nil, err
+	}
+
+	badge := &Badge{
+		Message: fmt.Sprintf("passed %s ago", formatAge(r.StopTime)),
+		Color:   badgeGreen,
+	}
+
+	if r.ExitCode != 0 {
+		badge.Message = fmt.Sprintf("failed %s ago", formatAge(r.StopTime))
+		badge.Color = badgeRed
+	}
+
+	return badge, nil
+}
+
+func getRateBadge(connection *pgx.Conn, tableName, host, command string, window time.Duration) (*Badge, error) {
+	conditions := badgeConditions(host, command)
+	conditions.Add("starttime >= $%d", time.Now().Add(-window))
+
+	statement := fmt.Sprintf(`SELECT COUNT(*), COALESCE(SUM(CASE WHEN exitcode = 0 THEN 1 ELSE 0 END), 0)::bigint
+FROM %s%s`, tableName, conditions.Where())
+
+	var runs, successes int
+	err := connection.QueryRow(context.Background(), statement, conditions.Args()...).Scan(&runs, &successes)
+	if err != nil {
+		return nil, err
+	}
+
+	if runs == 0 {
+		return &Badge{Message: "no runs", Color: badgeGrey}, nil
+	}
+
+	rate := float64(successes) / float64(runs)
+
+	badge := &Badge{
+		Message: fmt.Sprintf("%.1f%%", rate*100),
+		Color:   badgeRed,
+	}
+
+	switch {
+	case rate >= 0.99:
+		badge.Color = badgeGreen
+	case rate >= 0.9:
+		badge.Color = badgeYellow
+	}
+
+	return badge, nil
+}
+
+// GenerateBadge renders a shields-style badge, with the label on the left
+// and the message on a colored background on the right.
+func GenerateBadge(badge *Badge) []byte {
+	labelWidth := badgeCharacterWidth*float64(utf8.RuneCountInString(badge.Label)) + badgePadding
+	messageWidth := badgeCharacterWidth*float64(utf8.RuneCountInString(badge.Message)) + badgePadding
+	width := labelWidth + messageWidth
+
+	label := html.EscapeString(badge.Label)
+	message := html.EscapeString(badge.Message)
+
+	return fmt.Appendf(nil, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="20" role="img" aria-label="%s: %s">
+  <title>%s: %s</title>
+  <linearGradient id="s" x2="0" y2="100%%">
+    <stop offset="0" stop-color="#bbb" stop-opacity=".1"/>
+    <stop offset="1" stop-opacity=".1"/>
+  </linearGradient>
+  <clipPath id="r">
+    <rect width="%.0f" height="20" rx="3" fill="#fff"/>
+  </clipPath>
+  <g clip-path="url(#r)">
+    <rect width="%.0f" height="20" fill="#555"/>
+    <rect x="%.0f" width="%.0f" height="20" fill="%s"/>
+    <rect width="%.0f" height="20" fill="url(#s)"/>
+  </g>
+  <g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+    <text x="%.1f" y="15" fill="#010101" fill-opacity=".3">%s</text>
+    <text x="%.1f" y="14">%s</text>
+    <text x="%.1f" y="15" fill="#010101" fill-opacity=".3">%s</text>
+    <text x="%.1f" y="14">%s</text>
+  </g>
+</svg>
+`,
+		width, label, message,
+		label, message,
+		width,
+		labelWidth,
+		labelWidth, messageWidth, badge.Color,
+		width,
+		labelWidth/2, label,
+		labelWidth/2, label,
+		labelWidth+messageWidth/2, message,
+		labelWidth+messageWidth/2, message)
+}
+
+func GetBadge(database *Database, kind, host, command string, window time.Duration) (*Badge, error) {
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	if kind == "rate" {
+		return getRateBadge(connection, database.Table, host, command, window)
+	}
+
+	return getStatusBadge(connection, database.Table, host, command)
+}
+
+func ServeBadge(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		query := r.URL.Query()
+
+		host := query.Get("host")
+		command := query.Get("command")
+
+		badge, err := GetBadge(database, query.Get("type"), host, command, parseWindow(r, defaultBadgeWindow))
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		badge.Label = query.Get("label")
+		if badge.Label == "" {
+			badge.Label = command
+		}
+		if badge.Label == "" {
+			badge.Label = "commands"
+		}
+
+		svg := GenerateBadge(badge)
+
+		// The age shown changes over time even when the latest run does not,
+		// so the content itself, which reflects any change to the commands it
+		// summarizes, is used to validate cached copies of each query's badge.
+		hash := sha256.Sum256(fmt.Appendf(nil, "%s\x00%s", r.URL.RawQuery, svg))
+
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Header().Set("ETag", `"`+hex.EncodeToString(hash[:16])+`"`)
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", badgeMaxAge))
+
+		securityHeaders(w)
+
+		// Badges are meant to be embedded in pages served from other origins.
+		w.Header().Set("Cross-Origin-Resource-Policy", "cross-origin")
+
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(svg))
+	}
+}
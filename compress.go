@@ -0,0 +1,203 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	compressMinimumLength int = 1024
+)
+
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/xml",
+	"application/atom+xml",
+	"application/rss+xml",
+	"image/svg+xml",
+}
+
+var gzipWriters = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding header permits a
+// gzip-encoded response. An explicit gzip entry takes precedence over *.
+func acceptsGzip(r *http.Request) bool {
+	gzipWeight, anyWeight := -1.0, -1.0
+
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for encoding := range strings.SplitSeq(header, ",") {
+			name, params, _ := strings.Cut(encoding, ";")
+
+			weight := 1.0
+
+			for param := range strings.SplitSeq(params, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(key, "q") {
+					continue
+				}
+
+				var err error
+
+				weight, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil {
+					weight = 0
+				}
+			}
+
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case "gzip":
+				gzipWeight = weight
+			case "*":
+				anyWeight = weight
+			}
+		}
+	}
+
+	if gzipWeight >= 0 {
+		return gzipWeight > 0
+	}
+
+	return anyWeight > 0
+}
+
+func compressible(contentType string) bool {
+	// Streamed events must reach the client as they are written.
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		return false
+	}
+
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	accepted bool
+	decided  bool
+	gz       *gzip.Writer
+}
+
+// decide determines, once the status and headers are known, whether the
+// response should be compressed.
+func (w *gzipResponseWriter) decide(status int, data []byte) {
+	if w.decided {
+		return
+	}
+
+	w.decided = true
+
+	header := w.Header()
+
+	if header.Get("Content-Type") == "" && data != nil {
+		header.Set("Content-Type", http.DetectContentType(data))
+	}
+
+	if !compressible(header.Get("Content-Type")) {
+		return
+	}
+
+	header.Add("Vary", "Accept-Encoding")
+
+	switch {
+	case !w.accepted,
+		header.Get("Content-Encoding") != "",
+		status == http.StatusNoContent,
+		status == http.StatusNotModified,
+		status == http.StatusPartialContent:
+		return
+	}
+
+	if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil && length < compressMinimumLength {
+		return
+	}
+
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", "gzip")
+
+	// The compressed representation differs byte for byte from the original,
+	// so any strong validator is weakened.
+	if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		header.Set("ETag", "W/"+etag)
+	}
+
+	w.gz = gzipWriters.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if status >= 200 {
+		w.decide(status, nil)
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	w.decide(http.StatusOK, data)
+
+	if w.gz == nil {
+		return w.ResponseWriter.Write(data)
+	}
+
+	return w.gz.Write(data)
+}
+
+func (w *gzipResponseWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *gzipResponseWriter) close() {
+	if w.gz == nil {
+		return
+	}
+
+	err := w.gz.Close()
+	if err != nil {
+		w.gz.Reset(io.Discard)
+	}
+
+	gzipWriters.Put(w.gz)
+
+	w.gz = nil
+}
+
+// Compress gzip-encodes text responses for clients which accept it.
+func Compress(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer := &gzipResponseWriter{
+			ResponseWriter: w,
+			accepted:       r.Method != http.MethodHead && acceptsGzip(r),
+		}
+		defer writer.close()
+
+		handler.ServeHTTP(writer, r)
+	})
+}
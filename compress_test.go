@@ -0,0 +1,53 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		headers  []string
+		expected bool
+	}{
+		{headers: nil, expected: false},
+		{headers: []string{""}, expected: false},
+		{headers: []string{"gzip"}, expected: true},
+		{headers: []string{"GZIP"}, expected: true},
+		{headers: []string{"deflate, br"}, expected: false},
+		{headers: []string{"deflate, gzip"}, expected: true},
+		{headers: []string{" gzip ; q=0.5 "}, expected: true},
+		{headers: []string{"gzip;q=1.0"}, expected: true},
+		{headers: []string{"gzip;q=0.001"}, expected: true},
+		{headers: []string{"gzip;q=0"}, expected: false},
+		{headers: []string{"gzip;q=0.000"}, expected: false},
+		{headers: []string{"gzip;Q=0"}, expected: false},
+		{headers: []string{"gzip; q=0"}, expected: false},
+		{headers: []string{"gzip;q=invalid"}, expected: false},
+		{headers: []string{"gzip;level=1;q=0"}, expected: false},
+		{headers: []string{"*"}, expected: true},
+		{headers: []string{"*;q=0"}, expected: false},
+		{headers: []string{"gzip;q=0, *"}, expected: false},
+		{headers: []string{"*, gzip;q=0"}, expected: false},
+		{headers: []string{"*;q=0, gzip"}, expected: true},
+		{headers: []string{"gzip;q=0.5, *;q=0"}, expected: true},
+		{headers: []string{"identity", "gzip"}, expected: true},
+		{headers: []string{"gzip", "gzip;q=0"}, expected: false},
+	}
+
+	for _, test := range tests {
+		r := &http.Request{Header: http.Header{}}
+		for _, header := range test.headers {
+			r.Header.Add("Accept-Encoding", header)
+		}
+
+		accepted := acceptsGzip(r)
+		if accepted != test.expected {
+			t.Errorf("acceptsGzip(%q) = %t, expected %t", test.headers, accepted, test.expected)
+		}
+	}
+}
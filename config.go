@@ -0,0 +1,404 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Settings are those parts of the configuration which can be changed while
+// running, by reloading it. They are replaced as a whole, so that each reader
+// sees either the old or the new configuration, never a mix of the two.
+type Settings struct {
+	Generation     uint64
+	Verbose        bool
+	Admins         []string
+	AdminUser      string
+	AdminPass      string
+	AlertInterval  time.Duration
+	Alerts         *AlertConfig
+	AnomalyFactor  float64
+	BaselineWindow time.Duration
+	CommandGroup   string
+	Identities     map[string]string
+	ProxyUser      string
+	Schedules      []Schedule
+	StaleAfter     time.Duration
+	StreamInterval time.Duration
+	TrustedProxies *TrustedProxies
+}
+
+// reloadableFlags are applied on reload. Changes to any other flag are only
+// reported, as they require a restart.
+var reloadableFlags = []string{
+	"admin-pass",
+	"admins",
+	"admin-user",
+	"alert-interval",
+	"alert-rules",
+	"anomaly-factor",
+	"baseline-window",
+	"normalize-masks",
+	"normalize-rules",
+	"proxy-user-header",
+	"schedules",
+	"stale-after",
+	"stream-interval",
+	"tls-identities",
+	"trusted-proxies",
+	"verbose",
+}
+
+var activeSettings atomic.Pointer[Settings]
+
+// settingsGeneration numbers each configuration applied, so that cached
+// responses can be invalidated when it changes.
+var settingsGeneration atomic.Uint64
+
+// commandLineFlags are those set on the command line, which take precedence
+// over the environment and the config file, and so are never reloaded.
+var commandLineFlags = map[string]bool{}
+
+// startupFlags are the values of each flag at startup, against which changes
+// to non-reloadable settings are reported.
+var startupFlags = map[string]string{}
+
+// settings returns the settings currently in effect. Each request should use
+// a single snapshot of them throughout, so that a concurrent reload is not
+// applied partway through.
+func settings() *Settings {
+	return activeSettings.Load()
+}
+
+// storeSettings applies s, replacing the settings currently in effect.
+func storeSettings(s *Settings) {
+	s.Generation = settingsGeneration.Add(1)
+
+	activeSettings.Store(s)
+}
+
+// configString converts a value read by viper into the form accepted by
+// pflag, with lists, such as those in a config file, separated by commas.
+func configString(value any) string {
+	switch value := value.(type) {
+	case []string:
+		return strings.Join(value, ",")
+	case []any:
+		values := make([]string, len(value))
+		for i, v := range value {
+			values[i] = fmt.Sprintf("%v", v)
+		}
+
+		return strings.Join(values, ",")
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+// flagString returns the value of a flag, with lists separated by commas.
+func flagString(f *pflag.Flag) string {
+	if list, ok := f.Value.(pflag.SliceValue); ok {
+		return strings.Join(list.GetSlice(), ",")
+	}
+
+	return f.Value.String()
+}
+
+func splitList(value string) []string {
+	var values []string
+
+	for entry := range strings.SplitSeq(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry != "" {
+			values = append(values, entry)
+		}
+	}
+
+	return values
+}
+
+// newViper reads the environment and, if specified, the config file.
+func newViper(configFile string) (*viper.Viper, error) {
+	v := viper.New()
+
+	v.SetEnvPrefix("commands")
+
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+
+	v.AutomaticEnv()
+
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+
+		err := v.ReadInConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+// resolveFlags returns the value each flag would have if the process were
+// started now: its value on the command line if set there, otherwise its value
+// in the environment or config file, otherwise its default.
+func resolveFlags(cmd *cobra.Command, v *viper.Viper) map[string]string {
+	values := map[string]string{}
+
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		configName := strings.ReplaceAll(f.Name, "-", "_")
+
+		switch {
+		case commandLineFlags[f.Name]:
+			values[f.Name] = flagString(f)
+		case v.IsSet(configName):
+			values[f.Name] = configString(v.Get(configName))
+		case f.Value.Type() == "stringSlice":
+			values[f.Name] = strings.Trim(f.DefValue, "[]")
+		default:
+			values[f.Name] = f.DefValue
+		}
+	})
+
+	return values
+}
+
+// currentFlags returns the value of each flag as parsed, including those of
+// the root command, which hold the settings, when cmd is a subcommand.
+func currentFlags(cmd *cobra.Command) map[string]string {
+	values := map[string]string{}
+
+	for _, flags := range []*pflag.FlagSet{cmd.Root().Flags(), cmd.Flags()} {
+		flags.VisitAll(func(f *pflag.Flag) {
+			values[f.Name] = flagString(f)
+		})
+	}
+
+	return values
+}
+
+// LoadSettings validates the reloadable flags, and loads the files they refer
+// to, returning an error rather than partially applying an invalid
+// configuration.
+func LoadSettings(values map[string]string) (*Settings, error) {
+	var err error
+
+	s := &Settings{
+		Admins:    splitList(values["admins"]),
+		AdminUser: values["admin-user"],
+		AdminPass: values["admin-pass"],
+		ProxyUser: http.CanonicalHeaderKey(values["proxy-user-header"]),
+	}
+
+	s.Verbose, err = strconv.ParseBool(values["verbose"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid verbose setting %q", values["verbose"])
+	}
+
+	intervals := map[string]*time.Duration{
+		"alert-interval":  &s.AlertInterval,
+		"baseline-window": &s.BaselineWindow,
+		"stale-after":     &s.StaleAfter,
+		"stream-interval": &s.StreamInterval,
+	}
+
+	for name, interval := range intervals {
+		*interval, err = time.ParseDuration(values[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", name, values[name])
+		}
+
+		if *interval <= 0 {
+			return nil, errors.New("intervals must be positive")
+		}
+	}
+
+	s.AnomalyFactor, err = strconv.ParseFloat(values["anomaly-factor"], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid anomaly factor %q", values["anomaly-factor"])
+	}
+
+	if s.AnomalyFactor <= 1 {
+		return nil, errors.New("anomaly factor must be greater than 1")
+	}
+
+	if s.AdminUser == "" && s.AdminPass != "" || s.AdminUser != "" && s.AdminPass == "" {
+		return nil, errors.New("admin user and password must both be specified to require authentication")
+	}
+
+	if adminBind == "" && s.AdminUser != "" {
+		return nil, errors.New("admin address must be specified to require authentication for admin endpoints")
+	}
+
+	if tlsCert == "" && s.AdminUser != "" && !loopbackAddress(adminBind) {
+		return nil, errors.New("TLS certificate and keyfile must be specified to require authentication for admin endpoints on a non-loopback address")
+	}
+
+	if tlsClientCA == "" && s.ProxyUser == "" && len(s.Admins) > 0 {
+		return nil, errors.New("client CA or proxy user header must be specified to authenticate admins")
+	}
+
+	if tlsClientCA == "" && values["tls-identities"] != "" {
+		return nil, errors.New("client CA must be specified to map client certificates to users")
+	}
+
+	s.CommandGroup, err = CommandGroupExpression(values["normalize-rules"], splitList(values["normalize-masks"]))
+	if err != nil {
+		return nil, err
+	}
+
+	if path := values["alert-rules"]; path != "" {
+		s.Alerts, err = LoadAlertConfig(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if path := values["schedules"]; path != "" {
+		s.Schedules, err = LoadSchedules(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if path := values["tls-identities"]; path != "" {
+		s.Identities, err = LoadClientIdentities(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.TrustedProxies, err = ParseTrustedProxies(splitList(values["trusted-proxies"]))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.ProxyUser != "" && len(s.TrustedProxies.Prefixes) == 0 && !s.TrustedProxies.Unix {
+		return nil, errors.New("trusted proxies must be specified to accept users authenticated by them")
+	}
+
+	return s, nil
+}
+
+// ReloadConfig re-reads the environment and config file, and applies the
+// reloadable settings if they are valid. Changes to any other settings are
+// logged as requiring a restart.
+func ReloadConfig(cmd *cobra.Command) error {
+	v, err := newViper(configFile)
+	if err != nil {
+		return err
+	}
+
+	values := resolveFlags(cmd, v)
+
+	s, err := LoadSettings(values)
+	if err != nil {
+		return err
+	}
+
+	storeSettings(s)
+
+	for name, value := range values {
+		if slices.Contains(reloadableFlags, name) || value == startupFlags[name] {
+			continue
+		}
+
+		fmt.Printf("%s | CONFIG: Ignoring change to --%s, which requires a restart\n",
+			time.Now().Format(logDate),
+			name)
+	}
+
+	return nil
+}
+
+// watchConfigFile signals on reload whenever the config file, or the
+// directory containing it, changes.
+func watchConfigFile(ctx context.Context, reload chan<- os.Signal) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	file := filepath.Clean(configFile)
+
+	err = watcher.Add(filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			fmt.Println(err)
+		case event := <-watcher.Events:
+			if event.Has(fsnotify.Chmod) || filepath.Clean(event.Name) != file && !strings.HasPrefix(filepath.Base(event.Name), "..") {
+				continue
+			}
+
+			select {
+			case reload <- syscall.SIGHUP:
+			default:
+			}
+		}
+	}
+}
+
+// WatchConfig reloads the configuration on SIGHUP and, if --config-watch is
+// set, whenever the config file changes. An invalid configuration is logged
+// and the running one is kept.
+func WatchConfig(ctx context.Context, cmd *cobra.Command) {
+	reload := make(chan os.Signal, 1)
+
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+
+	if configWatch && configFile != "" {
+		go func() {
+			err := watchConfigFile(ctx, reload)
+			if err != nil {
+				fmt.Println(err)
+			}
+		}()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reload:
+			err := ReloadConfig(cmd)
+			if err != nil {
+				fmt.Printf("%s | CONFIG: Keeping running configuration: %v\n",
+					time.Now().Format(logDate),
+					err)
+
+				continue
+			}
+
+			fmt.Printf("%s | CONFIG: Reloaded configuration\n",
+				time.Now().Format(logDate))
+		}
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maximumCronSearch bounds how far into the future Next will look for a
+// matching time, so that impossible expressions (e.g. February 30th) terminate.
+const maximumCronSearch time.Duration = 5 * 366 * 24 * time.Hour
+
+var cronAliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type Cron struct {
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+	anyDom      bool
+	anyDow      bool
+}
+
+func parseCronField(field string, minimum, maximum int) (uint64, error) {
+	var bits uint64
+
+	for part := range strings.SplitSeq(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+		}
+
+		start, end := minimum, maximum
+
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			first, last, _ := strings.Cut(rangePart, "-")
+
+			var err error
+
+			start, err = strconv.Atoi(first)
+			if err != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+
+			end, err = strconv.Atoi(last)
+			if err != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			value, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+
+			start = value
+			if !hasStep {
+				end = value
+			}
+		}
+
+		if start < minimum || end > maximum || start > end {
+			return 0, fmt.Errorf("value %q out of range %d-%d", part, minimum, maximum)
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	return bits, nil
+}
+
+// ParseCron parses a standard five-field cron expression, or one of the
+// common @-prefixed aliases.
+func ParseCron(expression string) (*Cron, error) {
+	if alias, ok := cronAliases[expression]; ok {
+		expression = alias
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, errors.New("cron expression must have exactly five fields")
+	}
+
+	c := &Cron{
+		anyDom: fields[2] == "*",
+		anyDow: fields[4] == "*",
+	}
+
+	var err error
+
+	c.minutes, err = parseCronField(fields[0], 0, 59)
+	if err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+
+	c.hours, err = parseCronField(fields[1], 0, 23)
+	if err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+
+	c.daysOfMonth, err = parseCronField(fields[2], 1, 31)
+	if err != nil {
+		return nil, fmt.Errorf("day of month: %w", err)
+	}
+
+	c.months, err = parseCronField(fields[3], 1, 12)
+	if err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+
+	c.daysOfWeek, err = parseCronField(fields[4], 0, 7)
+	if err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+
+	// Both 0 and 7 represent Sunday.
+	if c.daysOfWeek&(1<<7) != 0 {
+		c.daysOfWeek |= 1
+	}
+
+	return c, nil
+}
+
+func (c *Cron) matchesDay(t time.Time) bool {
+	dom := c.daysOfMonth&(1<<uint(t.Day())) != 0
+	dow := c.daysOfWeek&(1<<uint(t.Weekday())) != 0
+
+	// As in cron(8), if both fields are restricted, either may match.
+	switch {
+	case c.anyDom && c.anyDow:
+		return true
+	case c.anyDom:
+		return dow
+	case c.anyDow:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Next returns the first time strictly after t which matches the expression,
+// or the zero time if there is none within a reasonable search window.
+func (c *Cron) Next(t time.Time) time.Time {
+	limit := t.Add(maximumCronSearch)
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for t.Before(limit) {
+		switch {
+		case c.months&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hours&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
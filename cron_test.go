@@ -0,0 +1,139 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func bitsOf(values ...int) uint64 {
+	var bits uint64
+
+	for _, value := range values {
+		bits |= 1 << uint(value)
+	}
+
+	return bits
+}
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		field    string
+		minimum  int
+		maximum  int
+		expected uint64
+		invalid  bool
+	}{
+		{field: "*", minimum: 0, maximum: 5, expected: bitsOf(0, 1, 2, 3, 4, 5)},
+		{field: "3", minimum: 0, maximum: 59, expected: bitsOf(3)},
+		{field: "1,3,5", minimum: 0, maximum: 59, expected: bitsOf(1, 3, 5)},
+		{field: "10-12", minimum: 0, maximum: 59, expected: bitsOf(10, 11, 12)},
+		{field: "*/15", minimum: 0, maximum: 59, expected: bitsOf(0, 15, 30, 45)},
+		{field: "0-59/20", minimum: 0, maximum: 59, expected: bitsOf(0, 20, 40)},
+		{field: "5/20", minimum: 0, maximum: 59, expected: bitsOf(5, 25, 45)},
+		{field: "1-10/4", minimum: 0, maximum: 59, expected: bitsOf(1, 5, 9)},
+		{field: "*/2", minimum: 1, maximum: 7, expected: bitsOf(1, 3, 5, 7)},
+		{field: "0,0", minimum: 0, maximum: 59, expected: bitsOf(0)},
+		{field: "59", minimum: 0, maximum: 59, expected: bitsOf(59)},
+		{field: "60", minimum: 0, maximum: 59, invalid: true},
+		{field: "0", minimum: 1, maximum: 31, invalid: true},
+		{field: "5-3", minimum: 0, maximum: 59, invalid: true},
+		{field: "5-", minimum: 0, maximum: 59, invalid: true},
+		{field: "-5", minimum: 0, maximum: 59, invalid: true},
+		{field: "1-60", minimum: 0, maximum: 59, invalid: true},
+		{field: "*/0", minimum: 0, maximum: 59, invalid: true},
+		{field: "*/-1", minimum: 0, maximum: 59, invalid: true},
+		{field: "*/x", minimum: 0, maximum: 59, invalid: true},
+		{field: "x", minimum: 0, maximum: 59, invalid: true},
+		{field: "", minimum: 0, maximum: 59, invalid: true},
+		{field: "1,", minimum: 0, maximum: 59, invalid: true},
+	}
+
+	for _, test := range tests {
+		bits, err := parseCronField(test.field, test.minimum, test.maximum)
+
+		switch {
+		case test.invalid && err == nil:
+			t.Errorf("parseCronField(%q, %d, %d) = %b, expected an error", test.field, test.minimum, test.maximum, bits)
+		case !test.invalid && err != nil:
+			t.Errorf("parseCronField(%q, %d, %d) returned error: %v", test.field, test.minimum, test.maximum, err)
+		case !test.invalid && bits != test.expected:
+			t.Errorf("parseCronField(%q, %d, %d) = %b, expected %b", test.field, test.minimum, test.maximum, bits, test.expected)
+		}
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expression string
+		invalid    bool
+	}{
+		{expression: "* * * * *"},
+		{expression: "@daily"},
+		{expression: "@hourly"},
+		{expression: "0 0 29 2 *"},
+		{expression: "0 0 * * 7"},
+		{expression: "  0   0 * * *  "},
+		{expression: "* * * *", invalid: true},
+		{expression: "* * * * * *", invalid: true},
+		{expression: "@often", invalid: true},
+		{expression: "", invalid: true},
+		{expression: "60 * * * *", invalid: true},
+		{expression: "* 24 * * *", invalid: true},
+		{expression: "* * 0 * *", invalid: true},
+		{expression: "* * 32 * *", invalid: true},
+		{expression: "* * * 0 *", invalid: true},
+		{expression: "* * * 13 *", invalid: true},
+		{expression: "* * * * 8", invalid: true},
+	}
+
+	for _, test := range tests {
+		_, err := ParseCron(test.expression)
+
+		switch {
+		case test.invalid && err == nil:
+			t.Errorf("ParseCron(%q) succeeded, expected an error", test.expression)
+		case !test.invalid && err != nil:
+			t.Errorf("ParseCron(%q) returned error: %v", test.expression, err)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// A Wednesday.
+	start := time.Date(2026, time.January, 14, 10, 30, 45, 0, time.UTC)
+
+	tests := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{expression: "* * * * *", expected: time.Date(2026, time.January, 14, 10, 31, 0, 0, time.UTC)},
+		{expression: "30 10 * * *", expected: time.Date(2026, time.January, 15, 10, 30, 0, 0, time.UTC)},
+		{expression: "*/15 * * * *", expected: time.Date(2026, time.January, 14, 10, 45, 0, 0, time.UTC)},
+		{expression: "@hourly", expected: time.Date(2026, time.January, 14, 11, 0, 0, 0, time.UTC)},
+		{expression: "@monthly", expected: time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{expression: "0 0 * * 7", expected: time.Date(2026, time.January, 18, 0, 0, 0, 0, time.UTC)},
+		{expression: "0 0 * * 0", expected: time.Date(2026, time.January, 18, 0, 0, 0, 0, time.UTC)},
+		// With both day fields restricted, either may match.
+		{expression: "0 0 1 * 5", expected: time.Date(2026, time.January, 16, 0, 0, 0, 0, time.UTC)},
+		{expression: "0 0 29 2 *", expected: time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{expression: "0 0 30 2 *", expected: time.Time{}},
+	}
+
+	for _, test := range tests {
+		c, err := ParseCron(test.expression)
+		if err != nil {
+			t.Errorf("ParseCron(%q) returned error: %v", test.expression, err)
+
+			continue
+		}
+
+		next := c.Next(start)
+		if !next.Equal(test.expected) {
+			t.Errorf("Next(%q) = %v, expected %v", test.expression, next, test.expected)
+		}
+	}
+}
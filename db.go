@@ -6,12 +6,19 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	recordColumns string = "id, starttime, stoptime, hostname, commandname, exitcode"
 )
 
 type Database struct {
@@ -22,19 +29,76 @@ type Database struct {
 type Parameters struct {
 	CommandCount int
 	ExitCode     int
+	Failed       bool
+	Anomalous    bool
 	HostName     string
 	CommandName  string
+	ExactCommand bool
+	CommandGroup string
+	From         time.Time
+	To           time.Time
+	Weekday      int
+	Hour         int
 	SortBy       string
 	SortOrder    string
+	Bucket       string
+	Live         bool
+}
+
+type Results struct {
+	Rows               []Row
+	TotalCommandCount  int
+	FailedCommandCount int
+	HostNames          []string
+	Histogram          *Histogram
+}
+
+var sortColumns = map[string]string{
+	"id":            "id",
+	"start_time":    "starttime",
+	"duration":      "duration",
+	"host_name":     "hostname",
+	"command_name":  "commandname",
+	"command_group": "command_group",
+	"exit_code":     "exitcode",
 }
 
 type Row struct {
-	RowNumber   int
-	StartTime   time.Time
-	Duration    time.Duration
-	HostName    string
-	CommandName string
-	ExitCode    int
+	ID           int64
+	StartTime    time.Time
+	Duration     time.Duration
+	HostName     string
+	CommandName  string
+	CommandGroup string
+	ExitCode     int
+	Anomalous    bool
+}
+
+type Record struct {
+	ID          int64     `json:"id"`
+	StartTime   time.Time `json:"start_time"`
+	StopTime    time.Time `json:"stop_time"`
+	HostName    string    `json:"host_name"`
+	CommandName string    `json:"command_name"`
+	ExitCode    int       `json:"exit_code"`
+}
+
+func (r Record) Duration() time.Duration {
+	return r.StopTime.Sub(r.StartTime)
+}
+
+func (r Record) MarshalJSON() ([]byte, error) {
+	type record Record
+
+	return json.Marshal(struct {
+		record
+		Duration        string  `json:"duration"`
+		DurationSeconds float64 `json:"duration_seconds"`
+	}{
+		record:          record(r),
+		Duration:        r.Duration().Truncate(time.Second).String(),
+		DurationSeconds: r.Duration().Seconds(),
+	})
 }
 
 func GetDatabaseURL() (string, error) {
@@ -66,9 +130,29 @@ func openDatabase(databaseURL string) (*pgx.Conn, error) {
 		return nil, err
 	}
 
+	registerTimestampCodec(connection)
+
 	return connection, nil
 }
 
+// registerTimestampCodec makes timestamps scanned from the table use the same
+// time zone as those passed to it as query parameters.
+//
+// The table's timestamps have no time zone, and hold the local time at which
+// each command ran. pgx already encodes parameters, such as time.Now() or the
+// start of a window, as their wall clock time in their own location, but by
+// default scans stored timestamps as UTC. Without this, every comparison
+// between a scanned time and the current time, such as the age of a host's
+// last run or how overdue a scheduled command is, would be off by the local
+// UTC offset, as would every displayed time.
+func registerTimestampCodec(connection *pgx.Conn) {
+	connection.TypeMap().RegisterType(&pgtype.Type{
+		Name:  "timestamp",
+		OID:   pgtype.TimestampOID,
+		Codec: &pgtype.TimestampCodec{ScanLocation: time.Local},
+	})
+}
+
 func closeDatabase(connection *pgx.Conn) error {
 	err := connection.Close(context.Background())
 	if err != nil {
@@ -102,54 +186,166 @@ func getFailedCommandCount(connection *pgx.Conn, tableName string) (int, error)
 	return failedCommandCount, nil
 }
 
-func getRecentCommands(connection *pgx.Conn, tableName string, parameters *Parameters) ([]Row, error) {
+// getLatestCommand returns the ID and stop time of the most recently inserted
+// command, which change whenever the listing might.
+func getLatestCommand(connection *pgx.Conn, tableName string) (int64, time.Time, error) {
+	statement := fmt.Sprintf(`SELECT id, stoptime FROM %s
+ORDER BY id DESC
+LIMIT 1`, tableName)
+
+	var id int64
+	var stopTime time.Time
+
+	err := connection.QueryRow(context.Background(), statement).Scan(&id, &stopTime)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, time.Time{}, nil
+	}
+
+	return id, stopTime, err
+}
+
+func scanRecords(rows pgx.Rows) ([]Record, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Record, error) {
+		var r Record
+
+		err := row.Scan(&r.ID, &r.StartTime, &r.StopTime, &r.HostName, &r.CommandName, &r.ExitCode)
+
+		return r, err
+	})
+}
+
+func getHostNames(connection *pgx.Conn, tableName string) ([]string, error) {
+	statement := fmt.Sprintf("SELECT DISTINCT hostname FROM %s ORDER BY hostname", tableName)
+
+	rows, err := connection.Query(context.Background(), statement)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+type Conditions struct {
+	clauses []string
+	args    []any
+}
+
+func (c *Conditions) Add(clause string, arg any) {
+	c.args = append(c.args, arg)
+	c.clauses = append(c.clauses, fmt.Sprintf(clause, len(c.args)))
+}
+
+// Bind adds an argument without a corresponding clause, returning the
+// expression with its placeholder filled in.
+func (c *Conditions) Bind(expression string, arg any) string {
+	c.args = append(c.args, arg)
+
+	return fmt.Sprintf(expression, len(c.args))
+}
+
+// Append adds a clause whose arguments have already been bound.
+func (c *Conditions) Append(clause string) {
+	c.clauses = append(c.clauses, clause)
+}
+
+func (c *Conditions) Where() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+
+	return "\nwhere " + strings.Join(c.clauses, "\nand ")
+}
+
+func (c *Conditions) Args() []any {
+	return c.args
+}
+
+func filterConditions(tableName string, parameters *Parameters, s *Settings) *Conditions {
+	c := &Conditions{}
+
+	if parameters.ExitCode != -1 {
+		c.Add("exitcode = $%d", parameters.ExitCode)
+	}
+
+	if parameters.Failed {
+		c.Add("exitcode <> $%d", 0)
+	}
+
+	if parameters.Anomalous {
+		c.Append(anomalyCondition(c, tableName, s))
+	}
+
+	if parameters.HostName != "" {
+		c.Add("hostname = $%d", parameters.HostName)
+	}
+
+	switch {
+	case parameters.CommandName != "" && parameters.ExactCommand:
+		c.Add("commandname = $%d", parameters.CommandName)
+	case parameters.CommandName != "":
+		c.Add("commandname like '%%' || $%d || '%%'", parameters.CommandName)
+	}
+
+	if parameters.CommandGroup != "" {
+		c.Add(strings.ReplaceAll(s.CommandGroup, "%", "%%")+" = $%d", parameters.CommandGroup)
+	}
+
+	if !parameters.From.IsZero() {
+		c.Add("starttime >= $%d", parameters.From)
+	}
+
+	if !parameters.To.IsZero() {
+		c.Add("starttime < $%d", parameters.To)
+	}
+
+	if parameters.Weekday != -1 {
+		c.Add("EXTRACT(DOW FROM starttime)::int = $%d", parameters.Weekday)
+	}
+
+	if parameters.Hour != -1 {
+		c.Add("EXTRACT(HOUR FROM starttime)::int = $%d", parameters.Hour)
+	}
+
+	return c
+}
+
+func getRecentCommands(connection *pgx.Conn, tableName string, parameters *Parameters, s *Settings) ([]Row, error) {
 	var rowSlice []Row
 
-	var whereClauses = 0
+	conditions := filterConditions(tableName, parameters, s)
 
 	var statement strings.Builder
 
-	statement.WriteString(fmt.Sprintf("%v\n%v\n%v\n%v\n%v\n%v\n%v\n%v %v",
+	statement.WriteString(fmt.Sprintf("%v\n%v\n%v\n%v\n%v\n%v\n%v\n%v\n%v\n%v %v",
 		"select",
-		"row_number() over() as row,",
+		"id,",
 		"date_trunc('second', starttime) as start_time,",
 		"date_trunc('second', (age(stoptime, starttime)::time)) as duration,",
 		"hostname as host_name,",
 		"commandname as command_name,",
-		"exitcode as exit_code",
+		s.CommandGroup+" as command_group,",
+		"exitcode as exit_code,",
+		anomalyCondition(conditions, tableName, s)+" as anomalous",
 		"from", tableName))
 
-	if parameters.ExitCode != -1 {
-		statement.WriteString(fmt.Sprintf("\nwhere exitcode = '%v'", parameters.ExitCode))
-		whereClauses += 1
-	}
+	statement.WriteString(conditions.Where())
 
-	if parameters.HostName != "" {
-		if whereClauses == 0 {
-			statement.WriteString("\nwhere ")
-		} else {
-			statement.WriteString("\nand ")
-		}
-		statement.WriteString(fmt.Sprintf("hostname = '%v'", parameters.HostName))
-		whereClauses += 1
+	sortBy, ok := sortColumns[parameters.SortBy]
+	if !ok {
+		sortBy = "starttime"
 	}
 
-	if parameters.CommandName != "" {
-		if whereClauses == 0 {
-			statement.WriteString("\nwhere ")
-		} else {
-			statement.WriteString("\nand ")
-		}
-		statement.WriteString(fmt.Sprintf("commandname like '%%%v%%'", parameters.CommandName))
-		whereClauses += 1
+	sortOrder := "desc"
+	if parameters.SortOrder == "asc" {
+		sortOrder = "asc"
 	}
 
-	statement.WriteString(fmt.Sprintf("\norder by %v %v\n", parameters.SortBy, parameters.SortOrder))
+	statement.WriteString(fmt.Sprintf("\norder by %v %v\n", sortBy, sortOrder))
 	statement.WriteString(fmt.Sprintf("limit %v;", strconv.Itoa(parameters.CommandCount)))
 
 	fmt.Printf("\n%s\n\n", statement.String())
 
-	rows, err := connection.Query(context.Background(), statement.String())
+	rows, err := connection.Query(context.Background(), statement.String(), conditions.Args()...)
 	if err != nil {
 		return rowSlice, err
 	}
@@ -157,42 +353,47 @@ func getRecentCommands(connection *pgx.Conn, tableName string, parameters *Param
 
 	for rows.Next() {
 		var r Row
-		err := rows.Scan(&r.RowNumber, &r.StartTime, &r.Duration, &r.HostName, &r.CommandName, &r.ExitCode)
+		err := rows.Scan(&r.ID, &r.StartTime, &r.Duration, &r.HostName, &r.CommandName, &r.CommandGroup, &r.ExitCode, &r.Anomalous)
 		if err != nil {
 			return rowSlice, err
 		}
 		rowSlice = append(rowSlice, r)
 	}
 
-	return rowSlice, nil
+	return rowSlice, rows.Err()
 }
 
-func RunQuery(database *Database, parameters *Parameters) ([]Row, int, int, error) {
-	connection, err := openDatabase(database.Url)
+func runQuery(connection *pgx.Conn, tableName string, parameters *Parameters, s *Settings) (*Results, error) {
+	totalCommandCount, err := getTotalCommandCount(connection, tableName)
 	if err != nil {
-		return []Row{}, 0, 0, err
+		return nil, err
 	}
-	defer func(connection *pgx.Conn) {
-		err := closeDatabase(connection)
-		if err != nil {
-			fmt.Println(err)
-		}
-	}(connection)
 
-	totalCommandCount, err := getTotalCommandCount(connection, database.Table)
+	failedCommandCount, err := getFailedCommandCount(connection, tableName)
 	if err != nil {
-		return []Row{}, 0, 0, err
+		return nil, err
 	}
 
-	failedCommandCount, err := getFailedCommandCount(connection, database.Table)
+	hostNames, err := getHostNames(connection, tableName)
 	if err != nil {
-		return []Row{}, 0, 0, err
+		return nil, err
 	}
 
-	commands, err := getRecentCommands(connection, database.Table, parameters)
+	commands, err := getRecentCommands(connection, tableName, parameters, s)
 	if err != nil {
-		return []Row{}, 0, 0, err
+		return nil, err
+	}
+
+	histogram, err := getHistogram(connection, tableName, parameters, parameters.Bucket, s)
+	if err != nil {
+		return nil, err
 	}
 
-	return commands, totalCommandCount, failedCommandCount, nil
+	return &Results{
+		Rows:               commands,
+		TotalCommandCount:  totalCommandCount,
+		FailedCommandCount: failedCommandCount,
+		HostNames:          hostNames,
+		Histogram:          histogram,
+	}, nil
 }
@@ -0,0 +1,265 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	detailDate            string        = `2006-01-02 15:04:05.000000 MST (-07:00)`
+	defaultPreviousRuns   int           = 10
+	defaultContextWindow  time.Duration = 30 * time.Minute
+	maximumContextWindow  time.Duration = 7 * 24 * time.Hour
+	maximumPreviousRuns   int           = 1000
+	detailTemplateColumns string        = `<th>id</th><th>start_time</th><th>duration</th><th>command_name</th><th>exit_code</th>`
+)
+
+type Detail struct {
+	Record       Record
+	Group        string
+	PreviousRuns []Record
+	Surrounding  []Record
+	RunCount     int
+	Window       time.Duration
+	Token        string
+	Listing      string
+}
+
+var detailTemplate = `  <p><a href="{{base}}/">Back to listing</a></p>
+    <h3>Command {{.Record.ID}}</h3>
+    <table>
+      <tbody>
+        <tr><th>id</th><td>{{.Record.ID}}</td></tr>
+        <tr><th>host_name</th><td>{{.Record.HostName}}</td></tr>
+        <tr><th>command_name</th><td>{{.Record.CommandName}}</td></tr>
+        <tr><th>command_group</th><td><a href="{{base}}/history?host_name={{.Record.HostName}}&command_group={{.Group}}">{{.Group}}</a></td></tr>
+        <tr{{if ne .Record.ExitCode 0}} class="failed"{{end}}><th>exit_code</th><td>{{.Record.ExitCode}}</td></tr>
+        <tr><th>start_time</th><td>{{formatTime .Record.StartTime}}</td></tr>
+        <tr><th>stop_time</th><td>{{formatTime .Record.StopTime}}</td></tr>
+        <tr><th>duration</th><td>{{.Record.Duration}}</td></tr>
+      </tbody>
+    </table>
+{{with .Token}}    <form method="post" action="{{base}}/commands/{{$.Record.ID}}/delete">
+      <input type="hidden" name="csrf_token" value="{{.}}">
+      <input type="hidden" name="listing" value="{{$.Listing}}">
+      <input type="submit" value="Delete this command">
+    </form>
+{{end}}    <h3>Previous {{.RunCount}} runs of this command on {{.Record.HostName}}</h3>
+    <table>
+      <thead>
+        <tr>
+          ` + detailTemplateColumns + `
+        </tr>
+      </thead>
+      <tbody>
+{{range .PreviousRuns}}{{template "record" .}}{{end}}      </tbody>
+    </table>
+    <h3>Other commands on {{.Record.HostName}} within {{.Window}} of this run</h3>
+    <table>
+      <thead>
+        <tr>
+          ` + detailTemplateColumns + `
+        </tr>
+      </thead>
+      <tbody>
+{{range .Surrounding}}{{template "record" .}}{{end}}      </tbody>
+    </table>
+{{define "record"}}        <tr{{if ne .ExitCode 0}} class="failed"{{end}}>
+          <td><a href="{{base}}/commands/{{.ID}}">{{.ID}}</a></td>
+          <td>{{formatTime .StartTime}}</td>
+          <td>{{.Duration}}</td>
+          <td>{{.CommandName}}</td>
+          <td>{{.ExitCode}}</td>
+        </tr>
+{{end}}`
+
+func getRecord(connection *pgx.Conn, tableName string, id int64) (Record, error) {
+	statement := fmt.Sprintf("SELECT %s FROM %s WHERE id = $1", recordColumns, tableName)
+
+	var r Record
+	err := connection.QueryRow(context.Background(), statement, id).
+		Scan(&r.ID, &r.StartTime, &r.StopTime, &r.HostName, &r.CommandName, &r.ExitCode)
+	if err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+func getCommandGroup(connection *pgx.Conn, tableName string, id int64, s *Settings) (string, error) {
+	statement := fmt.Sprintf("SELECT %s FROM %s WHERE id = $1", s.CommandGroup, tableName)
+
+	var group string
+	err := connection.QueryRow(context.Background(), statement, id).Scan(&group)
+
+	return group, err
+}
+
+func getPreviousRuns(connection *pgx.Conn, tableName string, record Record, count int) ([]Record, error) {
+	statement := fmt.Sprintf(`SELECT %s FROM %s
+WHERE hostname = $1 AND commandname = $2 AND starttime < $3 AND id <> $4
+ORDER BY starttime DESC
+LIMIT $5`, recordColumns, tableName)
+
+	rows, err := connection.Query(context.Background(), statement,
+		record.HostName, record.CommandName, record.StartTime, record.ID, count)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRecords(rows)
+}
+
+func getSurroundingRuns(connection *pgx.Conn, tableName string, record Record, window time.Duration) ([]Record, error) {
+	statement := fmt.Sprintf(`SELECT %s FROM %s
+WHERE hostname = $1 AND id <> $2 AND starttime < $3 AND stoptime > $4
+ORDER BY starttime`, recordColumns, tableName)
+
+	rows, err := connection.Query(context.Background(), statement,
+		record.HostName, record.ID, record.StopTime.Add(window), record.StartTime.Add(-window))
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRecords(rows)
+}
+
+func GetDetail(database *Database, id int64, runCount int, window time.Duration, s *Settings) (*Detail, error) {
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	record, err := getRecord(connection, database.Table, id)
+	if err != nil {
+		return nil, err
+	}
+
+	group, err := getCommandGroup(connection, database.Table, id, s)
+	if err != nil {
+		return nil, err
+	}
+
+	previousRuns, err := getPreviousRuns(connection, database.Table, record, runCount)
+	if err != nil {
+		return nil, err
+	}
+
+	surrounding, err := getSurroundingRuns(connection, database.Table, record, window)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Detail{
+		Record:       record,
+		Group:        group,
+		PreviousRuns: previousRuns,
+		Surrounding:  surrounding,
+		RunCount:     runCount,
+		Window:       window,
+	}, nil
+}
+
+func ConstructDetailPage(w io.Writer, detail *Detail) error {
+	t, err := template.New("detail").Funcs(template.FuncMap{
+		"base": templateBase,
+		"formatTime": func(t time.Time) string {
+			return t.Format(detailDate)
+		},
+	}).Parse(detailTemplate)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, GeneratePageHeader(fmt.Sprintf("Command %d", detail.Record.ID)))
+	if err != nil {
+		return err
+	}
+
+	err = t.Execute(w, detail)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, GeneratePageFooter())
+
+	return err
+}
+
+func ServeDetailHandler(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		startTime := time.Now()
+
+		s := settings()
+
+		id, err := strconv.ParseInt(p.ByName("id"), 10, 64)
+		if err != nil {
+			NotFound(w)
+
+			return
+		}
+
+		runCount, err := strconv.Atoi(r.URL.Query().Get("runs"))
+		if err != nil || runCount < 0 || runCount > maximumPreviousRuns {
+			runCount = defaultPreviousRuns
+		}
+
+		window, err := time.ParseDuration(r.URL.Query().Get("window"))
+		if err != nil || window < 0 || window > maximumContextWindow {
+			window = defaultContextWindow
+		}
+
+		detail, err := GetDetail(database, id, runCount, window, s)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			NotFound(w)
+
+			return
+		case err != nil:
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		if user, ok := requestAdmin(r, s); ok {
+			detail.Token = csrfToken(user, time.Now())
+			detail.Listing = listingQuery(r)
+		}
+
+		w.Header().Add("Content-Type", "text/html")
+
+		securityHeaders(w)
+
+		err = ConstructDetailPage(w, detail)
+		if err != nil {
+			fmt.Println(err)
+
+			return
+		}
+
+		fmt.Printf("Constructed HTML page for command %d in %v.\n",
+			id,
+			time.Since(startTime))
+	}
+}
@@ -0,0 +1,331 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultFeedEntries int = 50
+
+	// feedTagDate is the date in the tag URIs identifying feeds and their
+	// entries, which must not change once feeds have been published.
+	feedTagDate string = "2026"
+)
+
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type AtomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    AtomLink `xml:"link"`
+	Summary string   `xml:"summary"`
+}
+
+type AtomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []AtomLink  `xml:"link"`
+	Entries []AtomEntry `xml:"entry"`
+}
+
+type RSSGuid struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type RSSItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	PubDate     string  `xml:"pubDate"`
+	Guid        RSSGuid `xml:"guid"`
+}
+
+type RSSChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []RSSItem `xml:"item"`
+}
+
+type RSSFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel RSSChannel `xml:"channel"`
+}
+
+type Feed struct {
+	Tag      string
+	Title    string
+	Base     string
+	Link     string
+	Self     string
+	Query    string
+	Updated  time.Time
+	Failures []Record
+}
+
+func feedConditions(tableName string, parameters *Parameters, s *Settings) *Conditions {
+	conditions := filterConditions(tableName, parameters, s)
+	conditions.Add("exitcode <> $%d", 0)
+
+	return conditions
+}
+
+// getLatestFailure returns the most recent failure matching the given
+// parameters, which is used to validate cached copies of the feed without
+// running the full query.
+func getLatestFailure(connection *pgx.Conn, tableName string, parameters *Parameters, s *Settings) (int64, time.Time, error) {
+	conditions := feedConditions(tableName, parameters, s)
+
+	statement := fmt.Sprintf(`SELECT id, stoptime FROM %s%s
+ORDER BY id DESC
+LIMIT 1`, tableName, conditions.Where())
+
+	var id int64
+	var stopTime time.Time
+
+	err := connection.QueryRow(context.Background(), statement, conditions.Args()...).Scan(&id, &stopTime)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, time.Time{}, nil
+	}
+
+	return id, stopTime, err
+}
+
+func getFeedFailures(connection *pgx.Conn, tableName string, parameters *Parameters, s *Settings) ([]Record, error) {
+	conditions := feedConditions(tableName, parameters, s)
+
+	count := parameters.CommandCount
+	if count == defaultCommandCount {
+		count = defaultFeedEntries
+	}
+
+	statement := fmt.Sprintf(`SELECT %s FROM %s%s
+ORDER BY id DESC
+LIMIT %d`, recordColumns, tableName, conditions.Where(), count)
+
+	rows, err := connection.Query(context.Background(), statement, conditions.Args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRecords(rows)
+}
+
+func feedTitle(record Record) string {
+	return fmt.Sprintf("%s on %s exited with code %d", record.CommandName, record.HostName, record.ExitCode)
+}
+
+func feedSummary(record Record) string {
+	return fmt.Sprintf("Started at %s, stopped at %s after %v.",
+		record.StartTime.Format(time.RFC3339),
+		record.StopTime.Format(time.RFC3339),
+		record.Duration())
+}
+
+// feedTag prefixes the IDs of feeds and their entries. These must not change
+// when the address the feed is served from does, but must differ between
+// installations, and between tables within them, whose command IDs overlap.
+func feedTag(tableName string) string {
+	return fmt.Sprintf("tag:%s,%s:%s/", feedAuthority, feedTagDate, tableName)
+}
+
+func feedEntryID(tag string, record Record) string {
+	return fmt.Sprintf("%scommand/%d", tag, record.ID)
+}
+
+func (f *Feed) Atom() *AtomFeed {
+	feed := &AtomFeed{
+		ID:      f.Tag + "failures?" + f.Query,
+		Title:   f.Title,
+		Updated: f.Updated.Format(time.RFC3339),
+		Links: []AtomLink{
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+			{Href: f.Self, Rel: "self", Type: "application/atom+xml"},
+		},
+	}
+
+	for _, record := range f.Failures {
+		link := fmt.Sprintf("%s/commands/%d", f.Base, record.ID)
+
+		feed.Entries = append(feed.Entries, AtomEntry{
+			ID:      feedEntryID(f.Tag, record),
+			Title:   feedTitle(record),
+			Updated: record.StopTime.Format(time.RFC3339),
+			Link:    AtomLink{Href: link},
+			Summary: feedSummary(record),
+		})
+	}
+
+	return feed
+}
+
+func (f *Feed) RSS() *RSSFeed {
+	feed := &RSSFeed{
+		Version: "2.0",
+		Channel: RSSChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   "Commands which exited with a non-zero code.",
+			LastBuildDate: f.Updated.Format(time.RFC1123Z),
+		},
+	}
+
+	for _, record := range f.Failures {
+		link := fmt.Sprintf("%s/commands/%d", f.Base, record.ID)
+
+		feed.Channel.Items = append(feed.Channel.Items, RSSItem{
+			Title:       feedTitle(record),
+			Link:        link,
+			Description: feedSummary(record),
+			PubDate:     record.StopTime.Format(time.RFC1123Z),
+			Guid:        RSSGuid{Value: feedEntryID(f.Tag, record), IsPermaLink: false},
+		})
+	}
+
+	return feed
+}
+
+func ServeFeed(database *Database, format string) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		startTime := time.Now()
+
+		s := settings()
+
+		parameters := parseParameters(r)
+
+		connection, err := openDatabase(database.Url)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+		defer func(connection *pgx.Conn) {
+			err := closeDatabase(connection)
+			if err != nil {
+				fmt.Println(err)
+			}
+		}(connection)
+
+		latestID, updated, err := getLatestFailure(connection, database.Table, parameters, s)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		// Deleting a failure need not change the most recent one.
+		if changed := time.Unix(0, changedAt.Load()); changed.After(updated) {
+			updated = changed
+		}
+
+		// Filtering on anomalies also depends on the baselines, which are
+		// recomputed periodically.
+		var computed time.Time
+		if parameters.Anomalous {
+			computed = baselinesComputed(s)
+		}
+
+		// The feed only changes when a new matching failure is recorded, an
+		// admin changes commands, the configuration is reloaded or, if filtering
+		// on anomalies, the baselines are recomputed, so these, along with the
+		// query, identify each version of the feed.
+		hash := sha256.Sum256(fmt.Appendf(nil, "%s\x00%d\x00%s\x00%d\x00%d\x00%d",
+			format, s.Generation, r.URL.RawQuery, latestID, updated.UnixNano(), computed.Unix()))
+
+		w.Header().Set("ETag", `"`+hex.EncodeToString(hash[:16])+`"`)
+		if !updated.IsZero() {
+			w.Header().Set("Last-Modified", updated.UTC().Format(http.TimeFormat))
+		}
+
+		securityHeaders(w)
+
+		if notModified(r, w.Header().Get("ETag"), updated) {
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		failures, err := getFeedFailures(connection, database.Table, parameters, s)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		base := baseURL(r)
+		query := parameters.Query().Encode()
+
+		feed := &Feed{
+			Tag:      feedTag(database.Table),
+			Title:    "Failed commands",
+			Base:     base,
+			Link:     base + "/?" + query,
+			Self:     base + strings.TrimPrefix(r.URL.RequestURI(), basePath),
+			Query:    query,
+			Updated:  updated,
+			Failures: failures,
+		}
+
+		if feed.Updated.IsZero() {
+			feed.Updated = startTime
+		}
+
+		var document any = feed.Atom()
+		contentType := "application/atom+xml"
+
+		if format == "rss" {
+			document = feed.RSS()
+			contentType = "application/rss+xml"
+		}
+
+		data, err := xml.MarshalIndent(document, "", "  ")
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+
+		w.Write([]byte(xml.Header))
+		w.Write(append(data, '\n'))
+
+		fmt.Printf("Constructed %s feed of %d failures in %v.\n",
+			format,
+			len(failures),
+			time.Since(startTime))
+	}
+}
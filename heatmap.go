@@ -0,0 +1,283 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	calendarCell float64 = 12
+	calendarGap  float64 = 2
+	gridCell     float64 = 24
+	labelWidth   float64 = 32
+	labelHeight  float64 = 16
+)
+
+var heatmapColors = []string{"#eee", "#fcc", "#f88", "#e44", "#a00"}
+
+type Heatmaps struct {
+	Calendar *Histogram
+	Grid     [7][24]int
+}
+
+func getWeekdayHourCounts(connection *pgx.Conn, tableName string, parameters *Parameters, from, to time.Time, s *Settings) ([7][24]int, error) {
+	var grid [7][24]int
+
+	conditions := filterConditions(tableName, parameters, s)
+	conditions.Add("exitcode <> $%d", 0)
+	conditions.Add("starttime >= $%d", from)
+	conditions.Add("starttime < $%d", to)
+
+	statement := fmt.Sprintf(`SELECT EXTRACT(DOW FROM starttime)::int, EXTRACT(HOUR FROM starttime)::int, COUNT(*)
+FROM %s%s
+GROUP BY 1, 2`, tableName, conditions.Where())
+
+	rows, err := connection.Query(context.Background(), statement, conditions.Args()...)
+	if err != nil {
+		return grid, err
+	}
+
+	var weekday, hour, failures int
+	_, err = pgx.ForEachRow(rows, []any{&weekday, &hour, &failures}, func() error {
+		if weekday >= 0 && weekday < 7 && hour >= 0 && hour < 24 {
+			grid[weekday][hour] = failures
+		}
+
+		return nil
+	})
+
+	return grid, err
+}
+
+func GetHeatmaps(database *Database, parameters *Parameters, s *Settings) (*Heatmaps, []string, error) {
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	// Both heatmaps cover the year leading up to the end of the requested
+	// range, starting on a Sunday so that the calendar's columns are whole weeks.
+	to := parameters.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	from := truncateTime(to.AddDate(-1, 0, 1), "day")
+	from = from.AddDate(0, 0, -int(from.Weekday()))
+
+	yearly := *parameters
+	yearly.From = from
+	yearly.To = to
+
+	calendar, err := getHistogram(connection, database.Table, &yearly, "day", s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	grid, err := getWeekdayHourCounts(connection, database.Table, parameters, from, to, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hostNames, err := getHostNames(connection, database.Table)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Heatmaps{
+		Calendar: calendar,
+		Grid:     grid,
+	}, hostNames, nil
+}
+
+func heatmapColor(value, maximum int) string {
+	if value == 0 || maximum == 0 {
+		return heatmapColors[0]
+	}
+
+	level := 1 + (len(heatmapColors)-2)*value/maximum
+
+	return heatmapColors[min(level, len(heatmapColors)-1)]
+}
+
+func heatmapCell(link, title string, x, y, size float64, color string) string {
+	return fmt.Sprintf(`      <a href="%s"><title>%s</title><rect x="%.0f" y="%.0f" width="%.0f" height="%.0f" fill="%s"/></a>
+`,
+		html.EscapeString(link),
+		html.EscapeString(title),
+		x, y, size, size,
+		color)
+}
+
+// GenerateCalendarHeatmap renders failures per day as a calendar, with one
+// column per week and one row per weekday.
+func GenerateCalendarHeatmap(calendar *Histogram, parameters *Parameters) string {
+	maximum := 0
+	for _, b := range calendar.Buckets {
+		maximum = max(maximum, b.Failures)
+	}
+
+	weeks := (len(calendar.Buckets) + 6) / 7
+	step := calendarCell + calendarGap
+
+	var svg strings.Builder
+
+	svg.WriteString(fmt.Sprintf(`    <svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f">
+`, labelWidth+float64(weeks)*step, labelHeight+7*step))
+
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday += 2 {
+		svg.WriteString(fmt.Sprintf(`      <text x="0" y="%.0f" font-size="10">%s</text>
+`, labelHeight+float64(weekday)*step+calendarCell-2, weekday.String()[:3]))
+	}
+
+	month := time.Month(0)
+
+	for i, b := range calendar.Buckets {
+		week := float64(i / 7)
+
+		if b.Start.Month() != month && b.Start.Day() <= 7 {
+			month = b.Start.Month()
+
+			svg.WriteString(fmt.Sprintf(`      <text x="%.0f" y="10" font-size="10">%s</text>
+`, labelWidth+week*step, month.String()[:3]))
+		}
+
+		query := parameters.Query()
+		query.Set("failed", "true")
+		query.Set("from", b.Start.Format(formTime))
+		query.Set("to", nextBucket(b.Start, "day").Format(formTime))
+
+		svg.WriteString(heatmapCell(
+			basePath+"/?"+query.Encode(),
+			fmt.Sprintf("%s: %d failures", b.Start.Format(time.DateOnly), b.Failures),
+			labelWidth+week*step,
+			labelHeight+float64(b.Start.Weekday())*step,
+			calendarCell,
+			heatmapColor(b.Failures, maximum)))
+	}
+
+	svg.WriteString("    </svg>\n")
+
+	return svg.String()
+}
+
+// GenerateGridHeatmap renders failures by weekday and hour of the day.
+func GenerateGridHeatmap(grid [7][24]int, from, to time.Time, parameters *Parameters) string {
+	maximum := 0
+	for _, hours := range grid {
+		for _, failures := range hours {
+			maximum = max(maximum, failures)
+		}
+	}
+
+	var svg strings.Builder
+
+	svg.WriteString(fmt.Sprintf(`    <svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f">
+`, labelWidth+24*gridCell, labelHeight+7*gridCell))
+
+	for hour := 0; hour < 24; hour += 3 {
+		svg.WriteString(fmt.Sprintf(`      <text x="%.0f" y="10" font-size="10">%02d:00</text>
+`, labelWidth+float64(hour)*gridCell, hour))
+	}
+
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		svg.WriteString(fmt.Sprintf(`      <text x="0" y="%.0f" font-size="10">%s</text>
+`, labelHeight+float64(weekday)*gridCell+gridCell/2+3, weekday.String()[:3]))
+
+		for hour := range 24 {
+			failures := grid[weekday][hour]
+
+			query := parameters.Query()
+			query.Set("failed", "true")
+			query.Set("weekday", strconv.Itoa(int(weekday)))
+			query.Set("hour", strconv.Itoa(hour))
+			query.Set("from", from.Format(formTime))
+			query.Set("to", to.Format(formTime))
+
+			svg.WriteString(heatmapCell(
+				basePath+"/?"+query.Encode(),
+				fmt.Sprintf("%s %02d:00: %d failures", weekday, hour, failures),
+				labelWidth+float64(hour)*gridCell,
+				labelHeight+float64(weekday)*gridCell,
+				gridCell-calendarGap,
+				heatmapColor(failures, maximum)))
+		}
+	}
+
+	svg.WriteString("    </svg>\n")
+
+	return svg.String()
+}
+
+func ConstructHeatmapPage(w io.Writer, parameters *Parameters, heatmaps *Heatmaps, hostNames []string) error {
+	var page strings.Builder
+
+	page.WriteString(GeneratePageHeader("Failure Heatmaps"))
+	page.WriteString(generateBackLink())
+	page.WriteString(generateFilterForm("/heatmaps", parameters, hostNames))
+
+	page.WriteString(fmt.Sprintf("    <h3>Failures per day from %s to %s</h3>\n",
+		heatmaps.Calendar.From.Format(time.DateOnly),
+		heatmaps.Calendar.To.Format(time.DateOnly)))
+	page.WriteString(GenerateCalendarHeatmap(heatmaps.Calendar, parameters))
+
+	page.WriteString("    <h3>Failures by weekday and hour</h3>\n")
+	page.WriteString(GenerateGridHeatmap(heatmaps.Grid, heatmaps.Calendar.From, heatmaps.Calendar.To, parameters))
+
+	page.WriteString(GeneratePageFooter())
+
+	_, err := io.WriteString(w, page.String())
+
+	return err
+}
+
+func ServeHeatmaps(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		startTime := time.Now()
+
+		s := settings()
+
+		parameters := parseParameters(r)
+
+		heatmaps, hostNames, err := GetHeatmaps(database, parameters, s)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		w.Header().Add("Content-Type", "text/html")
+
+		securityHeaders(w)
+
+		err = ConstructHeatmapPage(w, parameters, heatmaps, hostNames)
+		if err != nil {
+			fmt.Println(err)
+
+			return
+		}
+
+		fmt.Printf("Constructed heatmap page in %v.\n", time.Since(startTime))
+	}
+}
@@ -0,0 +1,245 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	maximumBuckets int     = 1500
+	chartWidth     float64 = 1000
+	chartHeight    float64 = 120
+)
+
+type Bucket struct {
+	Start    time.Time `json:"start"`
+	Runs     int       `json:"runs"`
+	Failures int       `json:"failures"`
+}
+
+type Histogram struct {
+	Bucket  string    `json:"bucket"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Buckets []Bucket  `json:"buckets"`
+}
+
+func truncateTime(t time.Time, bucket string) time.Time {
+	switch bucket {
+	case "minute":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+}
+
+func nextBucket(t time.Time, bucket string) time.Time {
+	switch bucket {
+	case "minute":
+		return t.Add(time.Minute)
+	case "hour":
+		return t.Add(time.Hour)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// histogramRange determines the time range and bucket size to use, falling
+// back to sensible defaults for anything not specified by the caller.
+func histogramRange(parameters *Parameters, bucket string) (time.Time, time.Time, string) {
+	to := parameters.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	from := parameters.From
+	if from.IsZero() || !from.Before(to) {
+		switch bucket {
+		case "minute":
+			from = to.Add(-time.Hour)
+		case "day":
+			from = to.AddDate(0, 0, -30)
+		default:
+			from = to.Add(-24 * time.Hour)
+		}
+	}
+
+	switch bucket {
+	case "minute", "hour", "day":
+	default:
+		span := to.Sub(from)
+
+		switch {
+		case span <= 2*time.Hour:
+			bucket = "minute"
+		case span <= 7*24*time.Hour:
+			bucket = "hour"
+		default:
+			bucket = "day"
+		}
+	}
+
+	if bucket == "minute" && to.Sub(from) > time.Duration(maximumBuckets)*time.Minute {
+		bucket = "hour"
+	}
+
+	if bucket == "hour" && to.Sub(from) > time.Duration(maximumBuckets)*time.Hour {
+		bucket = "day"
+	}
+
+	if bucket == "day" && from.Before(to.AddDate(0, 0, -maximumBuckets)) {
+		from = to.AddDate(0, 0, -maximumBuckets)
+	}
+
+	return from, to, bucket
+}
+
+func getHistogram(connection *pgx.Conn, tableName string, parameters *Parameters, bucket string, s *Settings) (*Histogram, error) {
+	from, to, bucket := histogramRange(parameters, bucket)
+
+	conditions := filterConditions(tableName, parameters, s)
+	conditions.Add("starttime >= $%d", from)
+	conditions.Add("starttime < $%d", to)
+
+	statement := fmt.Sprintf(`SELECT date_trunc('%s', starttime) AS bucket,
+COUNT(*),
+SUM(CASE WHEN exitcode <> 0 THEN 1 ELSE 0 END)::bigint
+FROM %s%s
+GROUP BY 1
+ORDER BY 1`, bucket, tableName, conditions.Where())
+
+	rows, err := connection.Query(context.Background(), statement, conditions.Args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int64]Bucket)
+
+	var b Bucket
+	_, err = pgx.ForEachRow(rows, []any{&b.Start, &b.Runs, &b.Failures}, func() error {
+		counts[b.Start.Unix()] = b
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	histogram := &Histogram{
+		Bucket: bucket,
+		From:   from,
+		To:     to,
+	}
+
+	for t := truncateTime(from, bucket); t.Before(to); t = nextBucket(t, bucket) {
+		b, ok := counts[t.Unix()]
+		if !ok {
+			b = Bucket{Start: t}
+		}
+
+		histogram.Buckets = append(histogram.Buckets, b)
+	}
+
+	return histogram, nil
+}
+
+func GetHistogram(database *Database, parameters *Parameters, bucket string, s *Settings) (*Histogram, error) {
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	return getHistogram(connection, database.Table, parameters, bucket, s)
+}
+
+// GenerateHistogramChart renders the histogram as an inline SVG bar chart,
+// with failures stacked on top of successful runs.
+func GenerateHistogramChart(histogram *Histogram, parameters *Parameters) string {
+	if len(histogram.Buckets) == 0 {
+		return ""
+	}
+
+	maximum := 1
+	for _, b := range histogram.Buckets {
+		maximum = max(maximum, b.Runs)
+	}
+
+	width := chartWidth / float64(len(histogram.Buckets))
+
+	var chart strings.Builder
+
+	chart.WriteString(fmt.Sprintf(`    <svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %v %v" width="100%%" height="%v" preserveAspectRatio="none">
+      <rect width="%v" height="%v" fill="#f4f4f4"/>
+`, chartWidth, chartHeight, chartHeight, chartWidth, chartHeight))
+
+	for i, b := range histogram.Buckets {
+		if b.Runs == 0 {
+			continue
+		}
+
+		x := float64(i) * width
+		succeeded := chartHeight * float64(b.Runs-b.Failures) / float64(maximum)
+		failed := chartHeight * float64(b.Failures) / float64(maximum)
+
+		query := parameters.Query()
+		query.Set("from", b.Start.Format(formTime))
+		query.Set("to", nextBucket(b.Start, histogram.Bucket).Format(formTime))
+
+		chart.WriteString(fmt.Sprintf(`      <a href="%s"><title>%s: %d runs, %d failures</title>
+        <rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="#aaa"/>
+        <rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="#d44"/>
+      </a>
+`,
+			html.EscapeString(basePath+"/?"+query.Encode()),
+			b.Start.Format(time.DateTime),
+			b.Runs,
+			b.Failures,
+			x, chartHeight-succeeded, width, succeeded,
+			x, chartHeight-succeeded-failed, width, failed))
+	}
+
+	chart.WriteString(fmt.Sprintf(`    </svg>
+    <p>Runs per %s from %s to %s, with failures in red.</p>
+`,
+		histogram.Bucket,
+		histogram.From.Format(time.DateTime),
+		histogram.To.Format(time.DateTime)))
+
+	return chart.String()
+}
+
+func ServeHistogramJSON(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		parameters := parseParameters(r)
+
+		histogram, err := GetHistogram(database, parameters, parameters.Bucket, settings())
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		serveJSON(w, histogram)
+	}
+}
@@ -0,0 +1,356 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	sparklineHeight float64 = 40
+	timelineHeight  float64 = 16
+)
+
+type History struct {
+	HostName                string
+	Command                 string
+	Runs                    int
+	Failures                int
+	FirstRun                time.Time
+	LastSuccess             *time.Time
+	LastFailure             *time.Time
+	Streak                  int
+	Failing                 bool
+	MeanTimeBetweenFailures time.Duration
+	Records                 []Record
+}
+
+var historyTemplate = `  <p><a href="{{base}}/">Back to listing</a></p>
+    <h3>History of {{.Command}} on {{.HostName}}</h3>
+    <table>
+      <tbody>
+        <tr><th>runs</th><td>{{.Runs}}</td></tr>
+        <tr><th>failures</th><td>{{.Failures}}</td></tr>
+        <tr><th>first_run</th><td>{{formatTime .FirstRun}}</td></tr>
+        <tr><th>last_success</th><td>{{with .LastSuccess}}{{formatTime .}}{{else}}never{{end}}</td></tr>
+        <tr><th>last_failure</th><td>{{with .LastFailure}}{{formatTime .}}{{else}}never{{end}}</td></tr>
+        <tr{{if .Failing}} class="failed"{{end}}><th>current_streak</th><td>{{.Streak}} {{if .Failing}}failures{{else}}successes{{end}}</td></tr>
+        <tr><th>mtbf</th><td>{{if .MeanTimeBetweenFailures}}{{.MeanTimeBetweenFailures}}{{else}}n/a{{end}}</td></tr>
+      </tbody>
+    </table>
+    <h3>Duration of the last {{len .Records}} runs</h3>
+{{sparkline .Records}}    <h3>Outcome of the last {{len .Records}} runs</h3>
+{{timeline .Records}}    <table>
+      <thead>
+        <tr>
+          ` + detailTemplateColumns + `
+        </tr>
+      </thead>
+      <tbody>
+{{range reverse .Records}}        <tr{{if ne .ExitCode 0}} class="failed"{{end}}>
+          <td><a href="{{base}}/commands/{{.ID}}">{{.ID}}</a></td>
+          <td>{{formatTime .StartTime}}</td>
+          <td>{{.Duration}}</td>
+          <td>{{.CommandName}}</td>
+          <td>{{.ExitCode}}</td>
+        </tr>
+{{end}}      </tbody>
+    </table>
+`
+
+// historyParameters restricts the listing's parameters to those which
+// identify a command and time range, so that the history always includes
+// both successful and failed runs. Unlike in the listing, the command name
+// must match exactly, so that the history of one command does not include
+// others whose names contain it.
+func historyParameters(parameters *Parameters) *Parameters {
+	return &Parameters{
+		CommandCount: parameters.CommandCount,
+		ExitCode:     -1,
+		HostName:     parameters.HostName,
+		CommandName:  parameters.CommandName,
+		ExactCommand: true,
+		CommandGroup: parameters.CommandGroup,
+		From:         parameters.From,
+		To:           parameters.To,
+		Weekday:      -1,
+		Hour:         -1,
+		SortBy:       "start_time",
+		SortOrder:    "desc",
+	}
+}
+
+func getHistoryRecords(connection *pgx.Conn, tableName string, parameters *Parameters, s *Settings) ([]Record, error) {
+	conditions := filterConditions(tableName, parameters, s)
+
+	statement := fmt.Sprintf(`SELECT %s
+FROM %s%s
+ORDER BY starttime DESC
+LIMIT %d`, recordColumns, tableName, conditions.Where(), parameters.CommandCount)
+
+	rows, err := connection.Query(context.Background(), statement, conditions.Args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := scanRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Reverse(records)
+
+	return records, nil
+}
+
+func getHistorySummary(connection *pgx.Conn, tableName string, parameters *Parameters, history *History, s *Settings) error {
+	conditions := filterConditions(tableName, parameters, s)
+
+	statement := fmt.Sprintf(`SELECT COUNT(*),
+SUM(CASE WHEN exitcode <> 0 THEN 1 ELSE 0 END)::bigint,
+MIN(starttime),
+MAX(starttime),
+MAX(CASE WHEN exitcode = 0 THEN starttime END),
+MAX(CASE WHEN exitcode <> 0 THEN starttime END),
+MIN(CASE WHEN exitcode <> 0 THEN starttime END)
+FROM %s%s`, tableName, conditions.Where())
+
+	var failures *int
+	var firstRun, lastRun, firstFailure *time.Time
+
+	err := connection.QueryRow(context.Background(), statement, conditions.Args()...).
+		Scan(&history.Runs, &failures, &firstRun, &lastRun, &history.LastSuccess, &history.LastFailure, &firstFailure)
+	if err != nil || history.Runs == 0 {
+		return err
+	}
+
+	history.Failures = *failures
+	history.FirstRun = *firstRun
+
+	// The mean of the gaps between consecutive failures is the span from the
+	// first failure to the last, divided by the number of gaps.
+	if history.Failures > 1 {
+		history.MeanTimeBetweenFailures = (history.LastFailure.Sub(*firstFailure) / time.Duration(history.Failures-1)).Round(time.Second)
+	}
+
+	// The current streak is every run since the most recent run with the
+	// opposite outcome, or every run if there has never been one.
+	history.Failing = history.LastSuccess == nil ||
+		history.LastFailure != nil && history.LastFailure.After(*history.LastSuccess)
+
+	since := history.LastFailure
+	if history.Failing {
+		since = history.LastSuccess
+	}
+
+	if since == nil {
+		history.Streak = history.Runs
+
+		return nil
+	}
+
+	conditions.Add("starttime > $%d", *since)
+
+	statement = fmt.Sprintf("SELECT COUNT(*)\nFROM %s%s", tableName, conditions.Where())
+
+	return connection.QueryRow(context.Background(), statement, conditions.Args()...).Scan(&history.Streak)
+}
+
+func GetHistory(database *Database, parameters *Parameters, s *Settings) (*History, error) {
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	history := &History{
+		HostName: parameters.HostName,
+		Command:  parameters.CommandGroup,
+	}
+
+	if history.Command == "" {
+		history.Command = parameters.CommandName
+	}
+
+	err = getHistorySummary(connection, database.Table, parameters, history, s)
+	if err != nil {
+		return nil, err
+	}
+
+	history.Records, err = getHistoryRecords(connection, database.Table, parameters, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// GenerateSparkline renders the duration of each run as an inline SVG line,
+// with failed runs marked in red.
+func GenerateSparkline(records []Record) template.HTML {
+	if len(records) == 0 {
+		return ""
+	}
+
+	var longest time.Duration
+	for _, r := range records {
+		longest = max(longest, r.Duration())
+	}
+
+	longest = max(longest, time.Millisecond)
+
+	step := chartWidth / float64(max(len(records)-1, 1))
+
+	var points, markers strings.Builder
+
+	for i, r := range records {
+		x := float64(i) * step
+		y := sparklineHeight - sparklineHeight*float64(r.Duration())/float64(longest)
+
+		points.WriteString(fmt.Sprintf("%.2f,%.2f ", x, y))
+
+		if r.ExitCode != 0 {
+			markers.WriteString(fmt.Sprintf(`      <circle cx="%.2f" cy="%.2f" r="3" fill="#d44"><title>%s</title></circle>
+`, x, y, html.EscapeString(fmt.Sprintf("%s: %v, exit code %d", r.StartTime.Format(time.DateTime), r.Duration(), r.ExitCode))))
+		}
+	}
+
+	return template.HTML(fmt.Sprintf(`    <svg xmlns="http://www.w3.org/2000/svg" viewBox="-4 -4 %v %v" width="100%%" height="%v" preserveAspectRatio="none">
+      <polyline points="%s" fill="none" stroke="#555" stroke-width="1.5" vector-effect="non-scaling-stroke"/>
+%s    </svg>
+    <p>Longest run took %v.</p>
+`, chartWidth+8, sparklineHeight+8, sparklineHeight+8, strings.TrimSpace(points.String()), markers.String(), longest))
+}
+
+// GenerateTimeline renders the outcome of each run as a strip of cells,
+// each linking to the details of that run.
+func GenerateTimeline(records []Record) template.HTML {
+	if len(records) == 0 {
+		return ""
+	}
+
+	width := chartWidth / float64(len(records))
+
+	var timeline strings.Builder
+
+	timeline.WriteString(fmt.Sprintf(`    <svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %v %v" width="100%%" height="%v" preserveAspectRatio="none">
+`, chartWidth, timelineHeight, timelineHeight))
+
+	for i, r := range records {
+		color := "#4a4"
+		if r.ExitCode != 0 {
+			color = "#d44"
+		}
+
+		timeline.WriteString(fmt.Sprintf(`      <a href="%s/commands/%d"><title>%s</title><rect x="%.2f" y="0" width="%.2f" height="%v" fill="%s"/></a>
+`,
+			basePath,
+			r.ID,
+			html.EscapeString(fmt.Sprintf("%s: exit code %d", r.StartTime.Format(time.DateTime), r.ExitCode)),
+			float64(i)*width, width, timelineHeight,
+			color))
+	}
+
+	timeline.WriteString("    </svg>\n")
+
+	return template.HTML(timeline.String())
+}
+
+func ConstructHistoryPage(w io.Writer, history *History) error {
+	t, err := template.New("history").Funcs(template.FuncMap{
+		"base": templateBase,
+		"formatTime": func(t time.Time) string {
+			return t.Format(time.DateTime)
+		},
+		"reverse": func(records []Record) []Record {
+			reversed := slices.Clone(records)
+
+			slices.Reverse(reversed)
+
+			return reversed
+		},
+		"sparkline": GenerateSparkline,
+		"timeline":  GenerateTimeline,
+	}).Parse(historyTemplate)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, GeneratePageHeader(fmt.Sprintf("History of %s on %s", history.Command, history.HostName)))
+	if err != nil {
+		return err
+	}
+
+	err = t.Execute(w, history)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, GeneratePageFooter())
+
+	return err
+}
+
+func ServeHistory(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		startTime := time.Now()
+
+		s := settings()
+
+		parameters := historyParameters(parseParameters(r))
+
+		if parameters.HostName == "" || parameters.CommandName == "" && parameters.CommandGroup == "" {
+			BadRequest(w)
+
+			return
+		}
+
+		history, err := GetHistory(database, parameters, s)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		if history.Runs == 0 {
+			NotFound(w)
+
+			return
+		}
+
+		w.Header().Add("Content-Type", "text/html")
+
+		securityHeaders(w)
+
+		err = ConstructHistoryPage(w, history)
+		if err != nil {
+			fmt.Println(err)
+
+			return
+		}
+
+		fmt.Printf("Constructed history page for %d runs in %v.\n",
+			history.Runs,
+			time.Since(startTime))
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultHostWindow time.Duration = 7 * 24 * time.Hour
+)
+
+type Host struct {
+	Name        string    `json:"host_name"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Runs        int       `json:"runs"`
+	Failures    int       `json:"failures"`
+	LastFailure *Record   `json:"last_failure"`
+	Stale       bool      `json:"stale"`
+}
+
+type Inventory struct {
+	Window     Duration `json:"window"`
+	StaleAfter Duration `json:"stale_after"`
+	Hosts      []*Host  `json:"hosts"`
+}
+
+var hostsTemplate = `  <p><a href="{{base}}/">Back to listing</a></p>
+    <h3>{{len .Hosts}} hosts, with run and failure counts over the last {{.Window}}, and hosts which have not reported within {{.StaleAfter}} marked as stale</h3>
+    <table>
+      <thead>
+        <tr>
+          <th>host_name</th><th>first_seen</th><th>last_seen</th><th>runs</th><th>failures</th><th>last_failure</th><th>stale</th>
+        </tr>
+      </thead>
+      <tbody>
+{{range .Hosts}}        <tr{{if .Stale}} class="stale"{{else if ne .Failures 0}} class="failed"{{end}}>
+          <td><a href="{{base}}/?host_name={{.Name}}">{{.Name}}</a></td>
+          <td>{{formatTime .FirstSeen}}</td>
+          <td>{{formatTime .LastSeen}}</td>
+          <td>{{.Runs}}</td>
+          <td>{{if ne .Failures 0}}<a href="{{base}}/?host_name={{.Name}}&failed=true">{{.Failures}}</a>{{else}}0{{end}}</td>
+          <td>{{with .LastFailure}}<a href="{{base}}/commands/{{.ID}}">{{formatTime .StartTime}}</a> {{.CommandName}}{{end}}</td>
+          <td>{{if .Stale}}yes{{else}}no{{end}}</td>
+        </tr>
+{{end}}      </tbody>
+    </table>
+`
+
+func getHosts(connection *pgx.Conn, tableName string, since time.Time) ([]*Host, error) {
+	statement := fmt.Sprintf(`SELECT hostname,
+MIN(starttime),
+MAX(starttime),
+SUM(CASE WHEN starttime >= $1 THEN 1 ELSE 0 END)::bigint,
+SUM(CASE WHEN starttime >= $1 AND exitcode <> 0 THEN 1 ELSE 0 END)::bigint
+FROM %s
+GROUP BY hostname
+ORDER BY hostname`, tableName)
+
+	rows, err := connection.Query(context.Background(), statement, since)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (*Host, error) {
+		h := &Host{}
+
+		err := row.Scan(&h.Name, &h.FirstSeen, &h.LastSeen, &h.Runs, &h.Failures)
+
+		return h, err
+	})
+}
+
+func getLastFailures(connection *pgx.Conn, tableName string) (map[string]*Record, error) {
+	statement := fmt.Sprintf(`SELECT DISTINCT ON (hostname) %s
+FROM %s
+WHERE exitcode <> 0
+ORDER BY hostname, starttime DESC`, recordColumns, tableName)
+
+	rows, err := connection.Query(context.Background(), statement)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := scanRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	lastFailures := make(map[string]*Record, len(records))
+	for i := range records {
+		lastFailures[records[i].HostName] = &records[i]
+	}
+
+	return lastFailures, nil
+}
+
+func GetInventory(database *Database, window time.Duration, s *Settings) (*Inventory, error) {
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	now := time.Now()
+
+	hosts, err := getHosts(connection, database.Table, now.Add(-window))
+	if err != nil {
+		return nil, err
+	}
+
+	lastFailures, err := getLastFailures(connection, database.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	staleAfter := s.StaleAfter
+
+	for _, host := range hosts {
+		host.LastFailure = lastFailures[host.Name]
+		host.Stale = now.Sub(host.LastSeen) > staleAfter
+	}
+
+	return &Inventory{
+		Window:     Duration{window},
+		StaleAfter: Duration{staleAfter},
+		Hosts:      hosts,
+	}, nil
+}
+
+func parseWindow(r *http.Request, fallback time.Duration) time.Duration {
+	window, err := time.ParseDuration(r.URL.Query().Get("window"))
+	if err != nil || window <= 0 {
+		return fallback
+	}
+
+	return window
+}
+
+func ConstructHostsPage(w io.Writer, inventory *Inventory) error {
+	t, err := template.New("hosts").Funcs(template.FuncMap{
+		"base": templateBase,
+		"formatTime": func(t time.Time) string {
+			return t.Format(time.DateTime)
+		},
+	}).Parse(hostsTemplate)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, GeneratePageHeader("Host Inventory"))
+	if err != nil {
+		return err
+	}
+
+	err = t.Execute(w, inventory)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, GeneratePageFooter())
+
+	return err
+}
+
+func ServeHosts(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		startTime := time.Now()
+
+		s := settings()
+
+		inventory, err := GetInventory(database, parseWindow(r, defaultHostWindow), s)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		w.Header().Add("Content-Type", "text/html")
+
+		securityHeaders(w)
+
+		err = ConstructHostsPage(w, inventory)
+		if err != nil {
+			fmt.Println(err)
+
+			return
+		}
+
+		fmt.Printf("Constructed host inventory page for %d hosts in %v.\n",
+			len(inventory.Hosts),
+			time.Since(startTime))
+	}
+}
+
+func ServeHostsJSON(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		s := settings()
+
+		inventory, err := GetInventory(database, parseWindow(r, defaultHostWindow), s)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		serveJSON(w, inventory)
+	}
+}
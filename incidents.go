@@ -0,0 +1,290 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultIncidentWindow time.Duration = 5 * time.Minute
+	defaultIncidentRange  time.Duration = 7 * 24 * time.Hour
+	defaultIncidentHosts  int           = 2
+)
+
+type Incident struct {
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	Failures      int       `json:"failures"`
+	Hosts         []string  `json:"hosts"`
+	CommandGroups []string  `json:"command_groups"`
+	FirstFailure  Record    `json:"first_failure"`
+}
+
+type Incidents struct {
+	From      time.Time  `json:"from"`
+	To        time.Time  `json:"to"`
+	Window    Duration   `json:"window"`
+	MinHosts  int        `json:"min_hosts"`
+	Incidents []Incident `json:"incidents"`
+}
+
+var incidentsTemplate = `    <h3>{{len .Incidents}} incidents affecting at least {{.MinHosts}} hosts, with no more than {{.Window}} between failures, from {{formatTime .From}} to {{formatTime .To}}</h3>
+    <table>
+      <thead>
+        <tr>
+          <th>start</th><th>end</th><th>failures</th><th>first_failure</th><th>hosts</th><th>command_groups</th>
+        </tr>
+      </thead>
+      <tbody>
+{{range .Incidents}}        <tr class="failed">
+          <td><a href="{{listing .}}">{{formatTime .Start}}</a></td>
+          <td>{{formatTime .End}}</td>
+          <td>{{.Failures}}</td>
+          <td><a href="{{base}}/commands/{{.FirstFailure.ID}}">{{.FirstFailure.HostName}}</a></td>
+          <td>{{join .Hosts}}</td>
+          <td>{{join .CommandGroups}}</td>
+        </tr>
+{{end}}      </tbody>
+    </table>
+`
+
+type failure struct {
+	Record
+	CommandGroup string
+}
+
+func getFailures(connection *pgx.Conn, tableName string, parameters *Parameters, s *Settings) ([]failure, error) {
+	conditions := filterConditions(tableName, parameters, s)
+	conditions.Add("exitcode <> $%d", 0)
+
+	statement := fmt.Sprintf(`SELECT %s, %s
+FROM %s%s
+ORDER BY starttime, id`, recordColumns, s.CommandGroup, tableName, conditions.Where())
+
+	rows, err := connection.Query(context.Background(), statement, conditions.Args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (failure, error) {
+		var f failure
+
+		err := row.Scan(&f.ID, &f.StartTime, &f.StopTime, &f.HostName, &f.CommandName, &f.ExitCode, &f.CommandGroup)
+
+		return f, err
+	})
+}
+
+// clusterIncidents groups failures, ordered by start time, into incidents
+// wherever each failure started within window of the one before it, keeping
+// only those which affected at least minHosts distinct hosts.
+func clusterIncidents(failures []failure, window time.Duration, minHosts int) []Incident {
+	var incidents []Incident
+
+	flush := func(cluster []failure) {
+		if len(cluster) == 0 {
+			return
+		}
+
+		hosts := make([]string, 0, len(cluster))
+		groups := make([]string, 0, len(cluster))
+
+		for _, f := range cluster {
+			hosts = append(hosts, f.HostName)
+			groups = append(groups, f.CommandGroup)
+		}
+
+		slices.Sort(hosts)
+		slices.Sort(groups)
+
+		hosts = slices.Compact(hosts)
+		if len(hosts) < minHosts {
+			return
+		}
+
+		incidents = append(incidents, Incident{
+			Start:         cluster[0].StartTime,
+			End:           cluster[len(cluster)-1].StartTime,
+			Failures:      len(cluster),
+			Hosts:         hosts,
+			CommandGroups: slices.Compact(groups),
+			FirstFailure:  cluster[0].Record,
+		})
+	}
+
+	start := 0
+
+	for i := 1; i < len(failures); i++ {
+		if failures[i].StartTime.Sub(failures[i-1].StartTime) > window {
+			flush(failures[start:i])
+
+			start = i
+		}
+	}
+
+	flush(failures[start:])
+
+	slices.Reverse(incidents)
+
+	return incidents
+}
+
+func GetIncidents(database *Database, parameters *Parameters, window time.Duration, minHosts int, s *Settings) (*Incidents, []string, error) {
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	bounded := *parameters
+
+	if bounded.To.IsZero() {
+		bounded.To = time.Now()
+	}
+
+	if bounded.From.IsZero() || !bounded.From.Before(bounded.To) {
+		bounded.From = bounded.To.Add(-defaultIncidentRange)
+	}
+
+	failures, err := getFailures(connection, database.Table, &bounded, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hostNames, err := getHostNames(connection, database.Table)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Incidents{
+		From:      bounded.From,
+		To:        bounded.To,
+		Window:    Duration{window},
+		MinHosts:  minHosts,
+		Incidents: clusterIncidents(failures, window, minHosts),
+	}, hostNames, nil
+}
+
+func parseMinHosts(r *http.Request) int {
+	minHosts, err := strconv.Atoi(r.URL.Query().Get("min_hosts"))
+	if err != nil || minHosts < 1 {
+		return defaultIncidentHosts
+	}
+
+	return minHosts
+}
+
+func ConstructIncidentsPage(w io.Writer, parameters *Parameters, incidents *Incidents, hostNames []string) error {
+	t, err := template.New("incidents").Funcs(template.FuncMap{
+		"base": templateBase,
+		"formatTime": func(t time.Time) string {
+			return t.Format(time.DateTime)
+		},
+		"join": func(values []string) string {
+			return strings.Join(values, ", ")
+		},
+		"listing": func(incident Incident) string {
+			query := parameters.Query()
+			query.Set("failed", "true")
+			query.Set("from", incident.Start.Format(formTime))
+			query.Set("to", incident.End.Add(time.Minute).Format(formTime))
+
+			return basePath + "/?" + query.Encode()
+		},
+	}).Parse(incidentsTemplate)
+	if err != nil {
+		return err
+	}
+
+	var header strings.Builder
+
+	header.WriteString(GeneratePageHeader("Incidents"))
+	header.WriteString(generateBackLink())
+	header.WriteString(generateFilterForm("/incidents", parameters, hostNames))
+
+	_, err = io.WriteString(w, header.String())
+	if err != nil {
+		return err
+	}
+
+	err = t.Execute(w, incidents)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, GeneratePageFooter())
+
+	return err
+}
+
+func ServeIncidents(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		startTime := time.Now()
+
+		s := settings()
+
+		parameters := parseParameters(r)
+
+		incidents, hostNames, err := GetIncidents(database, parameters,
+			parseWindow(r, defaultIncidentWindow), parseMinHosts(r), s)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		w.Header().Add("Content-Type", "text/html")
+
+		securityHeaders(w)
+
+		err = ConstructIncidentsPage(w, parameters, incidents, hostNames)
+		if err != nil {
+			fmt.Println(err)
+
+			return
+		}
+
+		fmt.Printf("Constructed incidents page for %d incidents in %v.\n",
+			len(incidents.Incidents),
+			time.Since(startTime))
+	}
+}
+
+func ServeIncidentsJSON(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		s := settings()
+
+		incidents, _, err := GetIncidents(database, parseParameters(r),
+			parseWindow(r, defaultIncidentWindow), parseMinHosts(r), s)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		serveJSON(w, incidents)
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestClusterIncidents(t *testing.T) {
+	base := time.Date(2026, time.January, 14, 10, 0, 0, 0, time.UTC)
+
+	fail := func(id int64, offset time.Duration, host, group string) failure {
+		return failure{
+			Record: Record{
+				ID:        id,
+				StartTime: base.Add(offset),
+				StopTime:  base.Add(offset + time.Second),
+				HostName:  host,
+				ExitCode:  1,
+			},
+			CommandGroup: group,
+		}
+	}
+
+	type incident struct {
+		firstID  int64
+		failures int
+		hosts    []string
+		groups   []string
+	}
+
+	tests := []struct {
+		name     string
+		failures []failure
+		window   time.Duration
+		minHosts int
+		expected []incident
+	}{
+		{
+			name:     "no failures",
+			window:   5 * time.Minute,
+			minHosts: 2,
+		},
+		{
+			name: "single host is not an incident",
+			failures: []failure{
+				fail(1, 0, "web1", "backup"),
+				fail(2, time.Minute, "web1", "backup"),
+			},
+			window:   5 * time.Minute,
+			minHosts: 2,
+		},
+		{
+			name: "single host with a minimum of one",
+			failures: []failure{
+				fail(1, 0, "web1", "backup"),
+			},
+			window:   5 * time.Minute,
+			minHosts: 1,
+			expected: []incident{
+				{firstID: 1, failures: 1, hosts: []string{"web1"}, groups: []string{"backup"}},
+			},
+		},
+		{
+			name: "hosts and groups are sorted and deduplicated",
+			failures: []failure{
+				fail(1, 0, "web2", "sync"),
+				fail(2, time.Minute, "web1", "backup"),
+				fail(3, 2*time.Minute, "web2", "backup"),
+			},
+			window:   5 * time.Minute,
+			minHosts: 2,
+			expected: []incident{
+				{firstID: 1, failures: 3, hosts: []string{"web1", "web2"}, groups: []string{"backup", "sync"}},
+			},
+		},
+		{
+			name: "gap of exactly the window stays in one incident",
+			failures: []failure{
+				fail(1, 0, "web1", "backup"),
+				fail(2, 5*time.Minute, "web2", "backup"),
+			},
+			window:   5 * time.Minute,
+			minHosts: 2,
+			expected: []incident{
+				{firstID: 1, failures: 2, hosts: []string{"web1", "web2"}, groups: []string{"backup"}},
+			},
+		},
+		{
+			name: "gap beyond the window splits incidents",
+			failures: []failure{
+				fail(1, 0, "web1", "backup"),
+				fail(2, time.Minute, "web2", "backup"),
+				fail(3, 10*time.Minute, "web3", "sync"),
+				fail(4, 11*time.Minute, "web4", "sync"),
+			},
+			window:   5 * time.Minute,
+			minHosts: 2,
+			expected: []incident{
+				{firstID: 3, failures: 2, hosts: []string{"web3", "web4"}, groups: []string{"sync"}},
+				{firstID: 1, failures: 2, hosts: []string{"web1", "web2"}, groups: []string{"backup"}},
+			},
+		},
+		{
+			name: "chained failures extend an incident beyond the window",
+			failures: []failure{
+				fail(1, 0, "web1", "backup"),
+				fail(2, 4*time.Minute, "web2", "backup"),
+				fail(3, 8*time.Minute, "web3", "backup"),
+			},
+			window:   5 * time.Minute,
+			minHosts: 3,
+			expected: []incident{
+				{firstID: 1, failures: 3, hosts: []string{"web1", "web2", "web3"}, groups: []string{"backup"}},
+			},
+		},
+		{
+			name: "clusters below the minimum are dropped",
+			failures: []failure{
+				fail(1, 0, "web1", "backup"),
+				fail(2, time.Minute, "web1", "backup"),
+				fail(3, 10*time.Minute, "web1", "sync"),
+				fail(4, 11*time.Minute, "web2", "sync"),
+			},
+			window:   5 * time.Minute,
+			minHosts: 2,
+			expected: []incident{
+				{firstID: 3, failures: 2, hosts: []string{"web1", "web2"}, groups: []string{"sync"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		incidents := clusterIncidents(test.failures, test.window, test.minHosts)
+
+		if len(incidents) != len(test.expected) {
+			t.Errorf("%s: got %d incidents, expected %d", test.name, len(incidents), len(test.expected))
+
+			continue
+		}
+
+		for i, expected := range test.expected {
+			got := incidents[i]
+
+			if got.FirstFailure.ID != expected.firstID ||
+				got.Failures != expected.failures ||
+				!slices.Equal(got.Hosts, expected.hosts) ||
+				!slices.Equal(got.CommandGroups, expected.groups) {
+				t.Errorf("%s: incident %d = {first %d, failures %d, hosts %v, groups %v}, expected {first %d, failures %d, hosts %v, groups %v}",
+					test.name, i,
+					got.FirstFailure.ID, got.Failures, got.Hosts, got.CommandGroups,
+					expected.firstID, expected.failures, expected.hosts, expected.groups)
+			}
+
+			if !got.Start.Equal(got.FirstFailure.StartTime) || got.End.Before(got.Start) {
+				t.Errorf("%s: incident %d spans %v to %v", test.name, i, got.Start, got.End)
+			}
+		}
+	}
+}
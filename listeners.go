@@ -0,0 +1,326 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// systemdFirstFD is the first file descriptor passed by systemd socket
+	// activation, following stdin, stdout and stderr.
+	systemdFirstFD int = 3
+
+	shutdownTimeout time.Duration = 10 * time.Second
+)
+
+// systemdListeners returns the sockets passed via systemd socket activation,
+// if any were passed to this process.
+func systemdListeners() ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, nil
+	}
+
+	// These are only meant for this process, not for any it starts.
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]net.Listener, 0, count)
+
+	for fd := systemdFirstFD; fd < systemdFirstFD+count; fd++ {
+		file := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+
+		// FileListener duplicates the descriptor, so the original is closed
+		// either way.
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("socket activation: file descriptor %d: %w", fd, err)
+		}
+
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
+
+// lookupOwner resolves an owner of the form user[:group] to numeric IDs,
+// with -1 leaving either unchanged.
+func lookupOwner(owner string) (int, int, error) {
+	userName, groupName, _ := strings.Cut(owner, ":")
+
+	uid, gid := -1, -1
+
+	if userName != "" {
+		u, err := user.Lookup(userName)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		uid, err = strconv.Atoi(u.Uid)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if groupName != "" {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		gid, err = strconv.Atoi(g.Gid)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return uid, gid, nil
+}
+
+func listenUnix(path string) (net.Listener, error) {
+	// A socket left behind by a previous run would otherwise prevent
+	// binding, but any other kind of file is left alone.
+	info, err := os.Lstat(path)
+	if err == nil && info.Mode().Type() == fs.ModeSocket {
+		err = os.Remove(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mode, err := strconv.ParseUint(socketMode, 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid socket mode %q", socketMode)
+	}
+
+	// The socket is created accessible to this user alone, and only opened
+	// up once it has its final owner, so that no one else can connect in
+	// the meantime.
+	restore := restrictUmask()
+	listener, err := net.Listen("unix", path)
+	restore()
+	if err != nil {
+		return nil, err
+	}
+
+	if socketOwner != "" {
+		uid, gid, err := lookupOwner(socketOwner)
+		if err == nil {
+			err = os.Chown(path, uid, gid)
+		}
+		if err != nil {
+			listener.Close()
+
+			return nil, err
+		}
+	}
+
+	err = os.Chmod(path, fs.FileMode(mode))
+	if err != nil {
+		listener.Close()
+
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// Listen opens each of the addresses specified via --listen, which are either
+// unix:/path/to/socket or host:port, along with any sockets passed via systemd
+// socket activation. If there are neither, --bind and --port are used.
+func Listen() ([]net.Listener, error) {
+	listeners, err := systemdListeners()
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := listenAddresses
+	if len(addresses) == 0 && len(listeners) == 0 {
+		addresses = []string{net.JoinHostPort(bind, strconv.Itoa(int(port)))}
+	}
+
+	for _, address := range addresses {
+		var listener net.Listener
+
+		if path, ok := strings.CutPrefix(address, "unix:"); ok {
+			listener, err = listenUnix(path)
+		} else {
+			listener, err = net.Listen("tcp", address)
+		}
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+
+			return nil, err
+		}
+
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
+
+// unixListener reports whether the listener accepts connections via a Unix
+// socket, which are served over plain HTTP even when TLS is enabled, as they
+// are made from the local host, typically by a reverse proxy.
+func unixListener(listener net.Listener) bool {
+	return listener.Addr().Network() == "unix"
+}
+
+func listenerURL(listener net.Listener) string {
+	address := listener.Addr()
+
+	if unixListener(listener) {
+		return fmt.Sprintf("http+unix://%s", address.String())
+	}
+
+	return fmt.Sprintf("%s://%s/", scheme, address.String())
+}
+
+// httpsPort returns the port to which plain HTTP requests are redirected,
+// which is that of the first TCP listener.
+func httpsPort(listeners []net.Listener) string {
+	for _, listener := range listeners {
+		if address, ok := listener.Addr().(*net.TCPAddr); ok {
+			return strconv.Itoa(address.Port)
+		}
+	}
+
+	return strconv.Itoa(int(port))
+}
+
+// ServeRedirect permanently redirects every request to the same host and path
+// over HTTPS, on the given port.
+func ServeRedirect(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		securityHeaders(w)
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// Serve serves the handler on each of the listeners, along with the
+// redirecting listener if --redirect-listen is set and the admin server if
+// given, until any of them fails or the process is interrupted or terminated.
+// Every server is then shut down, which also removes the Unix sockets it
+// created.
+func Serve(srv *http.Server, listeners []net.Listener, admin *http.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, len(listeners)+2)
+
+	servers := []*http.Server{srv}
+
+	for _, listener := range listeners {
+		fmt.Printf("%s | Listening on %s\n",
+			time.Now().Format(logDate),
+			listenerURL(listener))
+
+		go func() {
+			if srv.TLSConfig != nil && !unixListener(listener) {
+				errs <- srv.ServeTLS(listener, "", "")
+			} else {
+				errs <- srv.Serve(listener)
+			}
+		}()
+	}
+
+	if redirectListen != "" {
+		redirect := &http.Server{
+			Addr:         redirectListen,
+			Handler:      ServeRedirect(httpsPort(listeners)),
+			IdleTimeout:  10 * time.Minute,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 5 * time.Second,
+		}
+
+		fmt.Printf("%s | Redirecting http://%s/ to HTTPS\n",
+			time.Now().Format(logDate),
+			redirect.Addr)
+
+		go func() {
+			errs <- redirect.ListenAndServe()
+		}()
+
+		servers = append(servers, redirect)
+	}
+
+	if admin != nil {
+		adminScheme := "http"
+		if admin.TLSConfig != nil {
+			adminScheme = "https"
+		}
+
+		fmt.Printf("%s | Serving admin endpoints on %s://%s/\n",
+			time.Now().Format(logDate),
+			adminScheme,
+			admin.Addr)
+
+		go func() {
+			if admin.TLSConfig != nil {
+				errs <- admin.ListenAndServeTLS("", "")
+			} else {
+				errs <- admin.ListenAndServe()
+			}
+		}()
+
+		servers = append(servers, admin)
+	}
+
+	var err error
+
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		fmt.Printf("%s | Shutting down\n", time.Now().Format(logDate))
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	for _, server := range servers {
+		shutdownErr := server.Shutdown(shutdownCtx)
+		if shutdownErr != nil {
+			fmt.Println(shutdownErr)
+		}
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,12 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+//go:build !unix
+
+package main
+
+// restrictUmask does nothing where there is no umask.
+func restrictUmask() func() {
+	return func() {}
+}
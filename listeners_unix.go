@@ -0,0 +1,19 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+//go:build unix
+
+package main
+
+import "syscall"
+
+// restrictUmask denies the group and other users any access to files created
+// until the returned function is called, which restores the previous umask.
+func restrictUmask() func() {
+	previous := syscall.Umask(0o177)
+
+	return func() {
+		syscall.Umask(previous)
+	}
+}
@@ -6,9 +6,9 @@ package main
 
 import (
 	"errors"
-	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -16,10 +16,20 @@ import (
 )
 
 const (
-	ReleaseVersion string = "1.1.1"
+	ReleaseVersion string = "1.26.0"
 )
 
 var (
+	adminBind        string
+	adminPass        string
+	adminUser        string
+	admins           []string
+	alertInterval    time.Duration
+	alertRules       string
+	anomalyFactor    float64
+	auditLog         string
+	basePath         string
+	baselineWindow   time.Duration
 	databaseType     string
 	databaseHost     string
 	databasePort     string
@@ -32,11 +42,37 @@ var (
 	databaseSslCert  string
 	databaseSslKey   string
 	bind             string
+	feedAuthority    string
+	configFile       string
+	configWatch      bool
+	listenAddresses  []string
+	normalizeMasks   []string
+	normalizeRules   string
 	port             uint16
 	profile          bool
+	proxyUserHeader  string
+	redirectListen   string
+	reportFormat     string
+	reportPeriod     time.Duration
 	scheme           string = "http"
+	schedulesFile    string
+	smtpFrom         string
+	smtpHost         string
+	smtpPass         string
+	smtpPort         uint16
+	smtpTo           []string
+	smtpUser         string
+	socketMode       string
+	socketOwner      string
+	staleAfter       time.Duration
+	streamInterval   time.Duration
 	tlsCert          string
+	tlsCipherPolicy  string
+	tlsClientCA      string
+	tlsIdentities    string
 	tlsKey           string
+	tlsMinVersion    string
+	trustedProxies   []string
 	verbose          bool
 	version          bool
 )
@@ -47,39 +83,138 @@ func main() {
 		Short: "Display command logs from a database.",
 		Args:  cobra.ExactArgs(0),
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			initializeConfig(cmd)
+			err := initializeConfig(cmd)
+			if err != nil {
+				return err
+			}
+
+			basePath = strings.TrimRight(basePath, "/")
+			if !basePathPattern.MatchString(basePath) {
+				return errors.New("base path must be of the form /path/to/app")
+			}
 
 			if tlsCert == "" && tlsKey != "" || tlsCert != "" && tlsKey == "" {
 				return errors.New("TLS certificate and keyfile must both be specified to enable HTTPS")
 			}
 
+			if configWatch && configFile == "" {
+				return errors.New("config file must be specified to watch it for changes")
+			}
+
+			if tlsCert == "" && redirectListen != "" {
+				return errors.New("TLS certificate and keyfile must be specified to redirect to HTTPS")
+			}
+
+			if tlsCert == "" && tlsClientCA != "" {
+				return errors.New("TLS certificate and keyfile must be specified to authenticate clients")
+			}
+
+			// Cobra only warns of deprecated flags given on the command line.
+			if profile && !commandLineFlags["profile"] {
+				cmd.PrintErrf("Flag --profile has been deprecated, %s\n",
+					cmd.Root().Flags().Lookup("profile").Deprecated)
+			}
+
+			s, err := LoadSettings(startupFlags)
+			if err != nil {
+				return err
+			}
+
+			storeSettings(s)
+
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return ServePage()
+			return ServePage(cmd)
 		},
 	}
 
-	cmd.Flags().StringVar(&databaseType, "db-type", "", "database type to connect to")
-	cmd.Flags().StringVar(&databaseHost, "db-host", "", "database host to connect to")
-	cmd.Flags().StringVar(&databasePort, "db-port", "", "database port to connect to")
-	cmd.Flags().StringVar(&databaseUser, "db-user", "", "database user to connect as")
-	cmd.Flags().StringVar(&databasePass, "db-pass", "", "database password to connect with")
-	cmd.Flags().StringVar(&databaseName, "db-name", "", "database name to connect to")
-	cmd.Flags().StringVar(&databaseTable, "db-table", "", "database table to query")
-	cmd.Flags().StringVar(&databaseSslMode, "db-ssl-mode", "", "database ssl connection mode")
-	cmd.Flags().StringVar(&databaseRootCert, "db-root-cert", "", "database ssl root certificate path")
-	cmd.Flags().StringVar(&databaseSslCert, "db-ssl-cert", "", "database ssl connection certificate path")
-	cmd.Flags().StringVar(&databaseSslKey, "db-ssl-key", "", "database ssl connection key path")
+	cmd.Flags().StringVar(&adminBind, "admin-bind", "", "address (host:port) on which to serve health, metrics, profiling and runtime information")
+	cmd.Flags().StringVar(&adminPass, "admin-pass", "", "password required to access the admin endpoints")
+	cmd.Flags().StringVar(&adminUser, "admin-user", "", "user required to access the admin endpoints")
+	cmd.Flags().StringSliceVar(&admins, "admins", []string{}, "users, as identified by their client certificates or a trusted proxy, permitted to delete and correct commands")
+	cmd.Flags().DurationVar(&alertInterval, "alert-interval", time.Minute, "interval at which to evaluate alert rules")
+	cmd.Flags().StringVar(&alertRules, "alert-rules", "", "path to alert rules file")
+	cmd.Flags().Float64Var(&anomalyFactor, "anomaly-factor", 3, "factor by which a run's duration must differ from its baseline median to be considered anomalous")
+	cmd.Flags().StringVar(&auditLog, "audit-log", "", "path to file to which to append a record of every change made by admins")
+	cmd.Flags().StringVar(&basePath, "base-path", "", "path prefix under which to serve all pages, e.g. when behind a reverse proxy")
+	cmd.Flags().DurationVar(&baselineWindow, "baseline-window", 7*24*time.Hour, "period of recent history from which duration baselines are computed")
+	cmd.PersistentFlags().StringVar(&configFile, "config", "", "path to config file (YAML, TOML or JSON), reloaded on SIGHUP")
+	cmd.Flags().BoolVar(&configWatch, "config-watch", false, "reload the config file whenever it changes")
+	cmd.PersistentFlags().StringVar(&databaseType, "db-type", "", "database type to connect to")
+	cmd.PersistentFlags().StringVar(&databaseHost, "db-host", "", "database host to connect to")
+	cmd.PersistentFlags().StringVar(&databasePort, "db-port", "", "database port to connect to")
+	cmd.PersistentFlags().StringVar(&databaseUser, "db-user", "", "database user to connect as")
+	cmd.PersistentFlags().StringVar(&databasePass, "db-pass", "", "database password to connect with")
+	cmd.PersistentFlags().StringVar(&databaseName, "db-name", "", "database name to connect to")
+	cmd.PersistentFlags().StringVar(&databaseTable, "db-table", "", "database table to query")
+	cmd.PersistentFlags().StringVar(&databaseSslMode, "db-ssl-mode", "", "database ssl connection mode")
+	cmd.PersistentFlags().StringVar(&databaseRootCert, "db-root-cert", "", "database ssl root certificate path")
+	cmd.PersistentFlags().StringVar(&databaseSslCert, "db-ssl-cert", "", "database ssl connection certificate path")
+	cmd.PersistentFlags().StringVar(&databaseSslKey, "db-ssl-key", "", "database ssl connection key path")
 	cmd.Flags().StringVarP(&bind, "bind", "b", "0.0.0.0", "address to bind to")
+	cmd.Flags().StringVar(&feedAuthority, "feed-authority", "", "domain name or email address identifying this installation in feed entry IDs (default hostname)")
+	cmd.Flags().StringSliceVar(&listenAddresses, "listen", []string{}, "addresses to listen on, as host:port or unix:/path/to/socket (overrides --bind and --port)")
+	cmd.PersistentFlags().StringSliceVar(&normalizeMasks, "normalize-masks", []string{}, "built-in masks to apply when grouping commands (any of numbers, uuids, dates, paths)")
+	cmd.PersistentFlags().StringVar(&normalizeRules, "normalize-rules", "", "path to command normalization rules file")
 	cmd.Flags().Uint16VarP(&port, "port", "p", 8080, "port to listen on")
-	cmd.Flags().BoolVar(&profile, "profile", false, "register net/http/pprof handlers")
+	cmd.Flags().BoolVar(&profile, "profile", false, "register net/http/pprof handlers (no longer has any effect)")
+	cmd.Flags().MarkDeprecated("profile", "it no longer has any effect; use --admin-bind to serve profiling handlers instead")
+	cmd.Flags().StringVar(&proxyUserHeader, "proxy-user-header", "", "header in which trusted proxies pass the user they authenticated, e.g. X-Forwarded-User")
+	cmd.Flags().StringVar(&redirectListen, "redirect-listen", "", "address (host:port) on which to redirect plain HTTP requests to HTTPS")
+	cmd.Flags().StringVar(&schedulesFile, "schedules", "", "path to scheduled commands file")
+	cmd.Flags().StringVar(&socketMode, "socket-mode", "0660", "file mode of unix sockets")
+	cmd.Flags().StringVar(&socketOwner, "socket-owner", "", "owner of unix sockets, as user[:group]")
+	cmd.Flags().DurationVar(&staleAfter, "stale-after", 24*time.Hour, "period after which hosts which have not reported are considered stale")
+	cmd.Flags().DurationVar(&streamInterval, "stream-interval", 5*time.Second, "interval at which to poll for new commands when LISTEN/NOTIFY is unavailable")
 	cmd.Flags().StringVar(&tlsCert, "tls-cert", "", "path to TLS certificate")
+	cmd.Flags().StringVar(&tlsCipherPolicy, "tls-cipher-policy", "default", "TLS cipher policy (default, intermediate or modern)")
+	cmd.Flags().StringVar(&tlsClientCA, "tls-client-ca", "", "path to CA certificates with which to require and verify client certificates")
+	cmd.Flags().StringVar(&tlsIdentities, "tls-identities", "", "path to file mapping client certificate subjects to users")
 	cmd.Flags().StringVar(&tlsKey, "tls-key", "", "path to TLS keyfile")
+	cmd.Flags().StringVar(&tlsMinVersion, "tls-min-version", "1.2", "minimum TLS version (1.2 or 1.3)")
+	cmd.Flags().StringSliceVar(&trustedProxies, "trusted-proxies", []string{}, "addresses or CIDR ranges of proxies whose X-Forwarded-For and X-Forwarded-Proto headers are honoured (\"unix\" for unix sockets)")
 	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "display additional output")
 	cmd.Flags().BoolVarP(&version, "version", "V", false, "display version and exit")
 	cmd.Flags().SetInterspersed(true)
 
+	cmd.AddCommand(&cobra.Command{
+		Use:   "setup",
+		Short: "Install the trigger used to stream new commands via LISTEN/NOTIFY.",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			databaseURL, err := GetDatabaseURL()
+			if err != nil {
+				return err
+			}
+
+			return SetupNotifications(&Database{
+				Url:   databaseURL,
+				Table: databaseTable,
+			})
+		},
+	})
+
+	report := &cobra.Command{
+		Use:   "report",
+		Short: "Generate a digest of commands run over a period.",
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return RunReport(cmd.OutOrStdout())
+		},
+	}
+
+	report.Flags().StringVar(&reportFormat, "format", "markdown", "report format (markdown, html or json)")
+	report.Flags().DurationVar(&reportPeriod, "period", 7*24*time.Hour, "period covered by the report")
+	report.Flags().StringVar(&smtpFrom, "smtp-from", "", "address from which to send the report")
+	report.Flags().StringVar(&smtpHost, "smtp-host", "", "SMTP server via which to send the report, instead of printing it")
+	report.Flags().StringVar(&smtpPass, "smtp-pass", "", "SMTP password to authenticate with")
+	report.Flags().Uint16Var(&smtpPort, "smtp-port", 25, "SMTP server port")
+	report.Flags().StringSliceVar(&smtpTo, "smtp-to", []string{}, "addresses to which to send the report")
+	report.Flags().StringVar(&smtpUser, "smtp-user", "", "SMTP user to authenticate as")
+
+	cmd.AddCommand(report)
+
 	cmd.CompletionOptions.HiddenDefaultCmd = true
 
 	cmd.Flags().SetInterspersed(true)
@@ -102,16 +237,36 @@ func main() {
 	}
 }
 
-func initializeConfig(cmd *cobra.Command) {
-	v := viper.New()
+func initializeConfig(cmd *cobra.Command) error {
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		commandLineFlags[f.Name] = true
+	})
 
-	v.SetEnvPrefix("commands")
+	v, err := newViper("")
+	if err != nil {
+		return err
+	}
 
-	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	if !commandLineFlags["config"] {
+		configFile = v.GetString("config")
+	}
 
-	v.AutomaticEnv()
+	if configFile != "" {
+		v, err = newViper(configFile)
+		if err != nil {
+			return err
+		}
+	}
 
 	bindFlags(cmd, v)
+
+	if cmd != cmd.Root() {
+		bindFlags(cmd.Root(), v)
+	}
+
+	startupFlags = currentFlags(cmd)
+
+	return nil
 }
 
 func bindFlags(cmd *cobra.Command, v *viper.Viper) {
@@ -120,7 +275,7 @@ func bindFlags(cmd *cobra.Command, v *viper.Viper) {
 
 		if !f.Changed && v.IsSet(configName) {
 			val := v.Get(configName)
-			cmd.Flags().Set(f.Name, fmt.Sprintf("%v", val))
+			cmd.Flags().Set(f.Name, configString(val))
 		}
 	})
 }
@@ -0,0 +1,245 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultMatrixWindow  time.Duration = 7 * 24 * time.Hour
+	defaultMatrixRefresh int           = 60
+)
+
+type MatrixRow struct {
+	HostName string
+	Cells    []*Record
+}
+
+type Matrix struct {
+	Hosts    string
+	Commands string
+	Window   time.Duration
+	Refresh  int
+	Groups   []string
+	Rows     []MatrixRow
+}
+
+var matrixTemplate = `  <p><a href="{{base}}/">Back to listing</a></p>
+    <form action="{{base}}/matrix" method="get">
+      <label>hosts <input type="text" name="hosts" value="{{.Hosts}}" placeholder="web*"></label>
+      <label>commands <input type="text" name="commands" value="{{.Commands}}" placeholder="backup*"></label>
+      <label>window <input type="text" name="window" value="{{.Window}}"></label>
+      <label>refresh <input type="number" name="refresh" min="0" value="{{.Refresh}}"> seconds</label>
+      <input type="submit" value="Filter">
+      <a href="{{base}}/matrix">Clear</a>
+    </form>
+    <h3>Latest run of each command group on each host within the last {{.Window}}</h3>
+    <table>
+      <thead>
+        <tr>
+          <th>host_name</th>{{range .Groups}}<th>{{.}}</th>{{end}}
+        </tr>
+      </thead>
+      <tbody>
+{{range $row := .Rows}}        <tr>
+          <th><a href="{{base}}/?host_name={{$row.HostName}}">{{$row.HostName}}</a></th>
+{{range $i, $cell := $row.Cells}}          {{with $cell}}<td class="{{if eq .ExitCode 0}}succeeded{{else}}failed{{end}}" title="{{formatTime .StartTime}}"><a href="{{base}}/history?host_name={{$row.HostName}}&command_group={{index $.Groups $i}}">{{.ExitCode}} ({{age .StartTime}})</a></td>{{else}}<td></td>{{end}}
+{{end}}        </tr>
+{{end}}      </tbody>
+    </table>
+`
+
+// formatAge renders the time since t in its largest whole unit.
+func formatAge(t time.Time) string {
+	age := time.Since(t)
+
+	switch {
+	case age < time.Minute:
+		return fmt.Sprintf("%ds", int(age.Seconds()))
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	}
+}
+
+// latestRun is the most recent run of a command group on a host.
+type latestRun struct {
+	Record
+	CommandGroup string
+}
+
+func getLatestRuns(connection *pgx.Conn, tableName, hosts, commands string, window time.Duration, s *Settings) ([]latestRun, error) {
+	group := s.CommandGroup
+
+	conditions := &Conditions{}
+	conditions.Add("starttime >= $%d", time.Now().Add(-window))
+
+	if hosts != "" {
+		conditions.Add("hostname like $%d", globToLike(hosts))
+	}
+
+	if commands != "" {
+		conditions.Add(strings.ReplaceAll(group, "%", "%%")+" like $%d", globToLike(commands))
+	}
+
+	statement := fmt.Sprintf(`SELECT DISTINCT ON (hostname, %[2]s) %[1]s, %[2]s
+FROM %[3]s%[4]s
+ORDER BY hostname, %[2]s, starttime DESC`, recordColumns, group, tableName, conditions.Where())
+
+	rows, err := connection.Query(context.Background(), statement, conditions.Args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (latestRun, error) {
+		var l latestRun
+
+		err := row.Scan(&l.ID, &l.StartTime, &l.StopTime, &l.HostName, &l.CommandName, &l.ExitCode, &l.CommandGroup)
+
+		return l, err
+	})
+}
+
+// buildMatrix arranges the latest runs, ordered by host and then command
+// group, into one row per host and one column per command group.
+func buildMatrix(runs []latestRun, matrix *Matrix) {
+	for _, run := range runs {
+		matrix.Groups = append(matrix.Groups, run.CommandGroup)
+	}
+
+	slices.Sort(matrix.Groups)
+	matrix.Groups = slices.Compact(matrix.Groups)
+
+	for _, run := range runs {
+		if len(matrix.Rows) == 0 || matrix.Rows[len(matrix.Rows)-1].HostName != run.HostName {
+			matrix.Rows = append(matrix.Rows, MatrixRow{
+				HostName: run.HostName,
+				Cells:    make([]*Record, len(matrix.Groups)),
+			})
+		}
+
+		column, _ := slices.BinarySearch(matrix.Groups, run.CommandGroup)
+
+		record := run.Record
+		matrix.Rows[len(matrix.Rows)-1].Cells[column] = &record
+	}
+}
+
+func GetMatrix(database *Database, hosts, commands string, window time.Duration, s *Settings) (*Matrix, error) {
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	runs, err := getLatestRuns(connection, database.Table, hosts, commands, window, s)
+	if err != nil {
+		return nil, err
+	}
+
+	matrix := &Matrix{
+		Hosts:    hosts,
+		Commands: commands,
+		Window:   window,
+	}
+
+	buildMatrix(runs, matrix)
+
+	return matrix, nil
+}
+
+func ConstructMatrixPage(w io.Writer, matrix *Matrix) error {
+	t, err := template.New("matrix").Funcs(template.FuncMap{
+		"base": templateBase,
+		"formatTime": func(t time.Time) string {
+			return t.Format(time.DateTime)
+		},
+		"age": formatAge,
+	}).Parse(matrixTemplate)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, GeneratePageHeader("Status Matrix"))
+	if err != nil {
+		return err
+	}
+
+	err = t.Execute(w, matrix)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, GeneratePageFooter())
+
+	return err
+}
+
+func ServeMatrix(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		startTime := time.Now()
+
+		s := settings()
+
+		query := r.URL.Query()
+
+		refresh, err := strconv.Atoi(query.Get("refresh"))
+		if err != nil || refresh < 0 {
+			refresh = defaultMatrixRefresh
+		}
+
+		matrix, err := GetMatrix(database, query.Get("hosts"), query.Get("commands"),
+			parseWindow(r, defaultMatrixWindow), s)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		matrix.Refresh = refresh
+
+		w.Header().Add("Content-Type", "text/html")
+
+		if refresh > 0 {
+			w.Header().Add("Refresh", strconv.Itoa(refresh))
+		}
+
+		securityHeaders(w)
+
+		err = ConstructMatrixPage(w, matrix)
+		if err != nil {
+			fmt.Println(err)
+
+			return
+		}
+
+		fmt.Printf("Constructed status matrix for %d hosts and %d command groups in %v.\n",
+			len(matrix.Rows),
+			len(matrix.Groups),
+			time.Since(startTime))
+	}
+}
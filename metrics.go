@@ -0,0 +1,102 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+type Metric struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WriteMetrics writes metrics in the Prometheus text exposition format.
+func WriteMetrics(w io.Writer, metrics []Metric) error {
+	var output strings.Builder
+
+	for _, metric := range metrics {
+		output.WriteString(fmt.Sprintf("# HELP %s %s\n", metric.Name, metric.Help))
+		output.WriteString(fmt.Sprintf("# TYPE %s %s\n", metric.Name, metric.Type))
+
+		for _, sample := range metric.Samples {
+			output.WriteString(metric.Name)
+
+			if len(sample.Labels) > 0 {
+				labels := make([]string, len(sample.Labels))
+				for i, label := range sample.Labels {
+					labels[i] = fmt.Sprintf(`%s="%s"`, label.Name, labelEscaper.Replace(label.Value))
+				}
+
+				output.WriteString("{" + strings.Join(labels, ",") + "}")
+			}
+
+			output.WriteString(" " + strconv.FormatFloat(sample.Value, 'g', -1, 64) + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, output.String())
+
+	return err
+}
+
+func ServeMetrics(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		s := settings()
+
+		baselines, err := GetBaselines(database, s)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		metrics := BaselineMetrics(baselines)
+
+		if schedules := s.Schedules; len(schedules) > 0 {
+			statuses, err := GetScheduleStatuses(database, schedules)
+			if err != nil {
+				fmt.Println(err)
+
+				ServerError(w, r, nil)
+
+				return
+			}
+
+			metrics = append(metrics, ScheduleMetrics(statuses)...)
+		}
+
+		w.Header().Add("Content-Type", "text/plain; version=0.0.4")
+
+		securityHeaders(w)
+
+		err = WriteMetrics(w, metrics)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
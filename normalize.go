@@ -0,0 +1,112 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// NormalizationRule rewrites every match of Pattern with Replacement. Both are
+// evaluated by the database, so patterns must be valid in both Go's RE2 syntax
+// and PostgreSQL's regular expression syntax, and replacements refer to
+// capture groups as \1, \2, and so on.
+type NormalizationRule struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+// builtinMasks are applied after any configured rules, in this order, so
+// that e.g. the digits within a UUID are not masked as numbers first.
+var builtinMasks = []struct {
+	Name string
+	Rule NormalizationRule
+}{
+	{"uuids", NormalizationRule{`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`, `<uuid>`}},
+	{"dates", NormalizationRule{`[0-9]{4}-?[0-9]{2}-?[0-9]{2}([T_ ]?[0-9]{2}:?[0-9]{2}(:?[0-9]{2})?)?`, `<date>`}},
+	{"paths", NormalizationRule{`(/[^/ ]+)+/`, `<path>/`}},
+	{"numbers", NormalizationRule{`(^|[^A-Za-z0-9_])[0-9]+`, `\1<n>`}},
+}
+
+func sqlLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func LoadNormalizationRules(path string) ([]NormalizationRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []NormalizationRule
+
+	err = json.Unmarshal(data, &rules)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return rules, nil
+}
+
+// GroupExpression builds the SQL expression which applies each configured
+// rule, followed by each requested built-in mask, to the command line.
+func GroupExpression(rules []NormalizationRule, masks []string) (string, error) {
+	known := make(map[string]bool, len(builtinMasks))
+	for _, builtin := range builtinMasks {
+		known[builtin.Name] = true
+	}
+
+	for _, mask := range masks {
+		if !known[mask] {
+			return "", fmt.Errorf("invalid normalization mask %q", mask)
+		}
+	}
+
+	rules = slices.Clone(rules)
+
+	for _, builtin := range builtinMasks {
+		if slices.Contains(masks, builtin.Name) {
+			rules = append(rules, builtin.Rule)
+		}
+	}
+
+	expression := "commandname"
+
+	for i, rule := range rules {
+		_, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return "", fmt.Errorf("normalization rule %d: %w", i, err)
+		}
+
+		expression = fmt.Sprintf("regexp_replace(%s, %s, %s, 'g')",
+			expression,
+			sqlLiteral(rule.Pattern),
+			sqlLiteral(rule.Replacement))
+	}
+
+	return expression, nil
+}
+
+// CommandGroupExpression builds the SQL expression used to derive a
+// command's group from its full command line, from the rules in the given
+// file, if any, and the given masks.
+func CommandGroupExpression(path string, masks []string) (string, error) {
+	var rules []NormalizationRule
+
+	if path != "" {
+		var err error
+
+		rules, err = LoadNormalizationRules(path)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return GroupExpression(rules, masks)
+}
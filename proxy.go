@@ -0,0 +1,155 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies are the addresses from which forwarded headers are honoured.
+// Unix socket connections, which have no address, are trusted if Unix is set.
+type TrustedProxies struct {
+	Prefixes []netip.Prefix
+	Unix     bool
+}
+
+// ParseTrustedProxies accepts CIDR ranges, bare IP addresses, or "unix" to
+// trust connections made via Unix sockets.
+func ParseTrustedProxies(entries []string) (*TrustedProxies, error) {
+	proxies := &TrustedProxies{}
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+
+		if entry == "unix" {
+			proxies.Unix = true
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			address, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+
+			prefix = netip.PrefixFrom(address, address.BitLen())
+		}
+
+		proxies.Prefixes = append(proxies.Prefixes, prefix.Masked())
+	}
+
+	return proxies, nil
+}
+
+func (t *TrustedProxies) Contains(address netip.Addr) bool {
+	address = address.Unmap()
+
+	for _, prefix := range t.Prefixes {
+		if prefix.Contains(address) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// trusted reports whether the peer a request was received from is a trusted
+// proxy.
+func (t *TrustedProxies) trusted(r *http.Request) bool {
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		// Requests received via Unix sockets have no remote address.
+		return t.Unix && (r.RemoteAddr == "" || r.RemoteAddr == "@")
+	}
+
+	return t.Contains(addrPort.Addr())
+}
+
+// clientAddress returns the address of the original client, which is the
+// rightmost entry in X-Forwarded-For not added by a trusted proxy.
+func (t *TrustedProxies) clientAddress(forwardedFor []string) (netip.Addr, error) {
+	var addresses []string
+	for _, header := range forwardedFor {
+		addresses = append(addresses, strings.Split(header, ",")...)
+	}
+
+	var client netip.Addr
+
+	for i := len(addresses) - 1; i >= 0; i-- {
+		address, err := netip.ParseAddr(strings.TrimSpace(addresses[i]))
+		if err != nil {
+			break
+		}
+
+		client = address.Unmap()
+
+		if !t.Contains(client) {
+			break
+		}
+	}
+
+	if !client.IsValid() {
+		return client, errors.New("no valid address in X-Forwarded-For")
+	}
+
+	return client, nil
+}
+
+// ForwardedHeaders honours X-Forwarded-For and X-Forwarded-Proto on requests
+// received from trusted proxies, so that the original client's address is
+// used in logs and authorization decisions, and the original scheme in
+// absolute links. With --proxy-user-header, the user the proxy authenticated
+// is recorded too. Those headers are removed from all other requests, so that
+// clients cannot spoof them.
+func ForwardedHeaders(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := settings()
+
+		proxies := s.TrustedProxies
+
+		if !proxies.trusted(r) {
+			r.Header.Del("X-Forwarded-For")
+			r.Header.Del("X-Forwarded-Proto")
+
+			if s.ProxyUser != "" {
+				r.Header.Del(s.ProxyUser)
+			}
+
+			handler.ServeHTTP(w, r)
+
+			return
+		}
+
+		if forwardedFor := r.Header.Values("X-Forwarded-For"); len(forwardedFor) > 0 {
+			client, err := proxies.clientAddress(forwardedFor)
+			if err == nil {
+				r.RemoteAddr = net.JoinHostPort(client.String(), "0")
+			}
+		}
+
+		switch proto := strings.ToLower(strings.TrimSpace(r.Header.Get("X-Forwarded-Proto"))); proto {
+		case "http", "https":
+			r.Header.Set("X-Forwarded-Proto", proto)
+		default:
+			r.Header.Del("X-Forwarded-Proto")
+		}
+
+		if s.ProxyUser != "" {
+			if user := strings.TrimSpace(r.Header.Get(s.ProxyUser)); user != "" {
+				r = r.WithContext(context.WithValue(r.Context(), identityKey{}, user))
+			}
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
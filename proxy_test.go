@@ -0,0 +1,140 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestClientAddress(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		forwardedFor []string
+		expected     string
+		invalid      bool
+	}{
+		{
+			name:         "single client",
+			forwardedFor: []string{"203.0.113.5"},
+			expected:     "203.0.113.5",
+		},
+		{
+			name:         "trusted proxies are skipped from the right",
+			forwardedFor: []string{"203.0.113.5, 10.0.0.2, 192.0.2.1"},
+			expected:     "203.0.113.5",
+		},
+		{
+			name:         "spoofed entries left of the client are ignored",
+			forwardedFor: []string{"198.51.100.7, 203.0.113.5, 10.0.0.2"},
+			expected:     "203.0.113.5",
+		},
+		{
+			name:         "spoofed trusted address left of the client is ignored",
+			forwardedFor: []string{"10.0.0.9, 203.0.113.5"},
+			expected:     "203.0.113.5",
+		},
+		{
+			name:         "entries are combined across headers",
+			forwardedFor: []string{"198.51.100.7, 203.0.113.5", "10.0.0.2"},
+			expected:     "203.0.113.5",
+		},
+		{
+			name:         "all trusted yields the leftmost",
+			forwardedFor: []string{"10.0.0.3, 10.0.0.2"},
+			expected:     "10.0.0.3",
+		},
+		{
+			name:         "invalid entry stops the search",
+			forwardedFor: []string{"203.0.113.5, unknown, 10.0.0.2"},
+			expected:     "10.0.0.2",
+		},
+		{
+			name:         "whitespace is ignored",
+			forwardedFor: []string{"  203.0.113.5 ,10.0.0.2  "},
+			expected:     "203.0.113.5",
+		},
+		{
+			name:         "IPv4-mapped IPv6 addresses are unmapped",
+			forwardedFor: []string{"::ffff:203.0.113.5, ::ffff:10.0.0.2"},
+			expected:     "203.0.113.5",
+		},
+		{
+			name:         "IPv6 trusted proxies",
+			forwardedFor: []string{"2001:db9::1, 2001:db8::1"},
+			expected:     "2001:db9::1",
+		},
+		{
+			name:         "rightmost invalid entry",
+			forwardedFor: []string{"203.0.113.5, unknown"},
+			invalid:      true,
+		},
+		{
+			name:         "empty header",
+			forwardedFor: []string{""},
+			invalid:      true,
+		},
+		{
+			name:         "no header",
+			forwardedFor: nil,
+			invalid:      true,
+		},
+	}
+
+	for _, test := range tests {
+		address, err := proxies.clientAddress(test.forwardedFor)
+
+		switch {
+		case test.invalid && err == nil:
+			t.Errorf("%s: clientAddress(%q) = %v, expected an error", test.name, test.forwardedFor, address)
+		case !test.invalid && err != nil:
+			t.Errorf("%s: clientAddress(%q) returned error: %v", test.name, test.forwardedFor, err)
+		case !test.invalid && address != netip.MustParseAddr(test.expected):
+			t.Errorf("%s: clientAddress(%q) = %v, expected %s", test.name, test.forwardedFor, address, test.expected)
+		}
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		entries []string
+		trusted []string
+		unix    bool
+		invalid bool
+	}{
+		{entries: []string{"10.0.0.0/8"}, trusted: []string{"10.1.2.3"}},
+		{entries: []string{"10.1.2.3/8"}, trusted: []string{"10.200.0.1"}},
+		{entries: []string{"192.0.2.1"}, trusted: []string{"192.0.2.1", "::ffff:192.0.2.1"}},
+		{entries: []string{" unix "}, unix: true},
+		{entries: []string{"proxy.example.com"}, invalid: true},
+		{entries: []string{"10.0.0.0/33"}, invalid: true},
+	}
+
+	for _, test := range tests {
+		proxies, err := ParseTrustedProxies(test.entries)
+
+		switch {
+		case test.invalid && err == nil:
+			t.Errorf("ParseTrustedProxies(%q) succeeded, expected an error", test.entries)
+		case !test.invalid && err != nil:
+			t.Errorf("ParseTrustedProxies(%q) returned error: %v", test.entries, err)
+		case !test.invalid:
+			if proxies.Unix != test.unix {
+				t.Errorf("ParseTrustedProxies(%q).Unix = %t, expected %t", test.entries, proxies.Unix, test.unix)
+			}
+
+			for _, address := range test.trusted {
+				if !proxies.Contains(netip.MustParseAddr(address)) {
+					t.Errorf("ParseTrustedProxies(%q) does not contain %s", test.entries, address)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,434 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	reportDate    string = "2006-01-02 15:04 MST"
+	reportEntries int    = 10
+)
+
+type Report struct {
+	From              time.Time   `json:"from"`
+	To                time.Time   `json:"to"`
+	Current           Aggregate   `json:"current"`
+	Previous          Aggregate   `json:"previous"`
+	FailureRateChange float64     `json:"failure_rate_change"`
+	TopFailing        []Aggregate `json:"top_failing"`
+	NewlyFailing      []Aggregate `json:"newly_failing"`
+	QuietHosts        []Aggregate `json:"quiet_hosts"`
+	Slowest           []Record    `json:"slowest"`
+}
+
+var reportMarkdown = `# Command report for {{date .From}} to {{date .To}}
+
+| | This period | Previous period |
+| --- | --- | --- |
+| Runs | {{.Current.Runs}} | {{.Previous.Runs}} |
+| Failures | {{.Current.Failures}} | {{.Previous.Failures}} |
+| Failure rate | {{percent .Current.FailureRate}} | {{percent .Previous.FailureRate}} |
+
+The failure rate {{change .FailureRateChange}} compared to the previous period.
+
+## Top failing commands
+{{with .TopFailing}}
+| Command | Failures | Runs | Failure rate |
+| --- | --- | --- | --- |
+{{range .}}| {{cell .Key}} | {{.Failures}} | {{.Runs}} | {{percent .FailureRate}} |
+{{end}}{{else}}
+None.
+{{end}}
+## Newly failing commands
+{{with .NewlyFailing}}
+| Command | Failures | Runs |
+| --- | --- | --- |
+{{range .}}| {{cell .Key}} | {{.Failures}} | {{.Runs}} |
+{{end}}{{else}}
+None.
+{{end}}
+## Hosts gone quiet
+{{with .QuietHosts}}
+| Host | Runs in previous period |
+| --- | --- |
+{{range .}}| {{cell .Key}} | {{.Runs}} |
+{{end}}{{else}}
+None.
+{{end}}
+## Slowest runs
+{{with .Slowest}}
+| ID | Host | Command | Started | Duration | Exit code |
+| --- | --- | --- | --- | --- | --- |
+{{range .}}| {{.ID}} | {{cell .HostName}} | {{cell .CommandName}} | {{date .StartTime}} | {{duration .Duration}} | {{.ExitCode}} |
+{{end}}{{else}}
+None.
+{{end}}`
+
+var reportHTML = `  <h2>Command report for {{date .From}} to {{date .To}}</h2>
+    <table>
+      <thead>
+        <tr><th></th><th>This period</th><th>Previous period</th></tr>
+      </thead>
+      <tbody>
+        <tr><th>Runs</th><td>{{.Current.Runs}}</td><td>{{.Previous.Runs}}</td></tr>
+        <tr><th>Failures</th><td>{{.Current.Failures}}</td><td>{{.Previous.Failures}}</td></tr>
+        <tr><th>Failure rate</th><td>{{percent .Current.FailureRate}}</td><td>{{percent .Previous.FailureRate}}</td></tr>
+      </tbody>
+    </table>
+    <p>The failure rate {{change .FailureRateChange}} compared to the previous period.</p>
+    <h3>Top failing commands</h3>
+{{with .TopFailing}}    <table>
+      <thead>
+        <tr><th>Command</th><th>Failures</th><th>Runs</th><th>Failure rate</th></tr>
+      </thead>
+      <tbody>
+{{range .}}        <tr class="failed"><td>{{.Key}}</td><td>{{.Failures}}</td><td>{{.Runs}}</td><td>{{percent .FailureRate}}</td></tr>
+{{end}}      </tbody>
+    </table>
+{{else}}    <p>None.</p>
+{{end}}    <h3>Newly failing commands</h3>
+{{with .NewlyFailing}}    <table>
+      <thead>
+        <tr><th>Command</th><th>Failures</th><th>Runs</th></tr>
+      </thead>
+      <tbody>
+{{range .}}        <tr class="failed"><td>{{.Key}}</td><td>{{.Failures}}</td><td>{{.Runs}}</td></tr>
+{{end}}      </tbody>
+    </table>
+{{else}}    <p>None.</p>
+{{end}}    <h3>Hosts gone quiet</h3>
+{{with .QuietHosts}}    <table>
+      <thead>
+        <tr><th>Host</th><th>Runs in previous period</th></tr>
+      </thead>
+      <tbody>
+{{range .}}        <tr><td>{{.Key}}</td><td>{{.Runs}}</td></tr>
+{{end}}      </tbody>
+    </table>
+{{else}}    <p>None.</p>
+{{end}}    <h3>Slowest runs</h3>
+{{with .Slowest}}    <table>
+      <thead>
+        <tr><th>ID</th><th>Host</th><th>Command</th><th>Started</th><th>Duration</th><th>Exit code</th></tr>
+      </thead>
+      <tbody>
+{{range .}}        <tr{{if ne .ExitCode 0}} class="failed"{{end}}><td>{{.ID}}</td><td>{{.HostName}}</td><td>{{.CommandName}}</td><td>{{date .StartTime}}</td><td>{{duration .Duration}}</td><td>{{.ExitCode}}</td></tr>
+{{end}}      </tbody>
+    </table>
+{{else}}    <p>None.</p>
+{{end}}`
+
+func reportParameters(from, to time.Time) *Parameters {
+	return &Parameters{
+		CommandCount: defaultCommandCount,
+		ExitCode:     -1,
+		From:         from,
+		To:           to,
+		Weekday:      -1,
+		Hour:         -1,
+		SortBy:       "start_time",
+		SortOrder:    "desc",
+	}
+}
+
+func getSlowestRuns(connection *pgx.Conn, tableName string, parameters *Parameters, limit int, s *Settings) ([]Record, error) {
+	conditions := filterConditions(tableName, parameters, s)
+
+	statement := fmt.Sprintf(`SELECT %s FROM %s%s
+ORDER BY stoptime - starttime DESC
+LIMIT %d`, recordColumns, tableName, conditions.Where(), limit)
+
+	rows, err := connection.Query(context.Background(), statement, conditions.Args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRecords(rows)
+}
+
+// getQuietHosts aggregates the runs of hosts which ran commands during the
+// previous period, but none during the current one.
+func getQuietHosts(connection *pgx.Conn, tableName string, previous, current *Parameters, s *Settings) ([]Aggregate, error) {
+	conditions := filterConditions(tableName, previous, s)
+
+	conditions.Append(fmt.Sprintf(`NOT EXISTS (SELECT 1 FROM %[1]s recent
+WHERE recent.hostname = %[1]s.hostname AND recent.starttime >= %[2]s AND recent.starttime < %[3]s)`,
+		tableName,
+		conditions.Bind("$%d", current.From),
+		conditions.Bind("$%d", current.To)))
+
+	statement := fmt.Sprintf(`SELECT hostname AS key,
+%s
+FROM %s%s
+GROUP BY 1
+ORDER BY key
+LIMIT %d`, aggregateColumns, tableName, conditions.Where(), previous.CommandCount)
+
+	rows, err := connection.Query(context.Background(), statement, conditions.Args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanAggregates(rows)
+}
+
+func failingKeys(aggregates []Aggregate) map[string]bool {
+	keys := make(map[string]bool, len(aggregates))
+
+	for _, a := range aggregates {
+		if a.Failures > 0 {
+			keys[a.Key] = true
+		}
+	}
+
+	return keys
+}
+
+// GetReport summarizes the period of the given length leading up to end,
+// compared with the period of the same length before it.
+func GetReport(database *Database, end time.Time, period time.Duration, s *Settings) (*Report, error) {
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	report := &Report{
+		From:         end.Add(-period),
+		To:           end,
+		TopFailing:   []Aggregate{},
+		NewlyFailing: []Aggregate{},
+		QuietHosts:   []Aggregate{},
+	}
+
+	current := reportParameters(report.From, report.To)
+	previous := reportParameters(report.From.Add(-period), report.From)
+
+	report.Current, err = getTotalAggregate(connection, database.Table, current, s)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Previous, err = getTotalAggregate(connection, database.Table, previous, s)
+	if err != nil {
+		return nil, err
+	}
+
+	report.FailureRateChange = (report.Current.FailureRate - report.Previous.FailureRate) * 100
+
+	commands, err := getAggregates(connection, database.Table, current,
+		s.CommandGroup, "3 DESC, key", current.CommandCount, s)
+	if err != nil {
+		return nil, err
+	}
+
+	previousCommands, err := getAggregates(connection, database.Table, previous,
+		s.CommandGroup, "3 DESC, key", previous.CommandCount, s)
+	if err != nil {
+		return nil, err
+	}
+
+	previouslyFailing := failingKeys(previousCommands)
+
+	for _, command := range commands {
+		if command.Failures == 0 {
+			break
+		}
+
+		if len(report.TopFailing) < reportEntries {
+			report.TopFailing = append(report.TopFailing, command)
+		}
+
+		if !previouslyFailing[command.Key] {
+			report.NewlyFailing = append(report.NewlyFailing, command)
+		}
+	}
+
+	quietHosts, err := getQuietHosts(connection, database.Table, previous, current, s)
+	if err != nil {
+		return nil, err
+	}
+
+	report.QuietHosts = append(report.QuietHosts, quietHosts...)
+
+	report.Slowest, err = getSlowestRuns(connection, database.Table, current, reportEntries, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func reportFuncs() map[string]any {
+	return map[string]any{
+		"date": func(t time.Time) string {
+			return t.Format(reportDate)
+		},
+		"percent": func(rate float64) string {
+			return fmt.Sprintf("%.1f%%", rate*100)
+		},
+		"change": func(points float64) string {
+			switch {
+			case points > 0:
+				return fmt.Sprintf("rose by %.1f percentage points", points)
+			case points < 0:
+				return fmt.Sprintf("fell by %.1f percentage points", -points)
+			default:
+				return "was unchanged"
+			}
+		},
+		"duration": func(d time.Duration) string {
+			return d.Truncate(time.Second).String()
+		},
+		"cell": func(value string) string {
+			return strings.ReplaceAll(value, "|", `\|`)
+		},
+	}
+}
+
+// WriteReport renders the report in the given format, one of markdown, html
+// or json.
+func WriteReport(w io.Writer, report *Report, format string) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(append(data, '\n'))
+
+		return err
+	case "html":
+		t, err := htmltemplate.New("report").Funcs(reportFuncs()).Parse(reportHTML)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(w, GeneratePageHeader("Command Report"))
+		if err != nil {
+			return err
+		}
+
+		err = t.Execute(w, report)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(w, GeneratePageFooter()+"\n")
+
+		return err
+	case "markdown":
+		t, err := template.New("report").Funcs(reportFuncs()).Parse(reportMarkdown)
+		if err != nil {
+			return err
+		}
+
+		return t.Execute(w, report)
+	}
+
+	return fmt.Errorf("invalid report format %q", format)
+}
+
+func reportContentType(format string) string {
+	switch format {
+	case "json":
+		return "application/json"
+	case "html":
+		return "text/html"
+	default:
+		return "text/markdown"
+	}
+}
+
+// SendReport delivers the rendered report via the SMTP server specified by
+// --smtp-host and --smtp-port, authenticating only if --smtp-user is set.
+func SendReport(report *Report, format string, body []byte) error {
+	if smtpFrom == "" || len(smtpTo) == 0 {
+		return errors.New("sender and recipients must be specified to send reports via SMTP")
+	}
+
+	var message bytes.Buffer
+
+	fmt.Fprintf(&message, "From: %s\r\n", smtpFrom)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(smtpTo, ", "))
+	fmt.Fprintf(&message, "Subject: Command report for %s to %s\r\n",
+		report.From.Format(reportDate),
+		report.To.Format(reportDate))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: %s; charset=utf-8\r\n", reportContentType(format))
+	fmt.Fprintf(&message, "\r\n")
+
+	message.Write(bytes.ReplaceAll(bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n")), []byte("\n"), []byte("\r\n")))
+
+	var auth smtp.Auth
+	if smtpUser != "" {
+		auth = smtp.PlainAuth("", smtpUser, smtpPass, smtpHost)
+	}
+
+	return smtp.SendMail(net.JoinHostPort(smtpHost, strconv.Itoa(int(smtpPort))), auth, smtpFrom, smtpTo, message.Bytes())
+}
+
+func RunReport(w io.Writer) error {
+	if reportPeriod <= 0 {
+		return errors.New("report period must be positive")
+	}
+
+	switch reportFormat {
+	case "markdown", "html", "json":
+	default:
+		return fmt.Errorf("invalid report format %q", reportFormat)
+	}
+
+	databaseURL, err := GetDatabaseURL()
+	if err != nil {
+		return err
+	}
+
+	report, err := GetReport(&Database{
+		Url:   databaseURL,
+		Table: databaseTable,
+	}, time.Now(), reportPeriod, settings())
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+
+	err = WriteReport(&body, report, reportFormat)
+	if err != nil {
+		return err
+	}
+
+	if smtpHost == "" {
+		_, err = w.Write(body.Bytes())
+
+		return err
+	}
+
+	return SendReport(report, reportFormat, body.Bytes())
+}
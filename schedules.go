@@ -0,0 +1,349 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	ScheduleOk      string = "ok"
+	ScheduleLate    string = "late"
+	ScheduleMissing string = "missing"
+	ScheduleFailing string = "failing"
+)
+
+var scheduleStatuses = []string{ScheduleOk, ScheduleLate, ScheduleMissing, ScheduleFailing}
+
+type Schedule struct {
+	Name     string   `json:"name"`
+	Host     string   `json:"host"`
+	Command  string   `json:"command"`
+	Interval Duration `json:"interval"`
+	Cron     string   `json:"cron"`
+	Grace    Duration `json:"grace"`
+	cron     *Cron
+}
+
+type ScheduleStatus struct {
+	Schedule       *Schedule `json:"schedule"`
+	Status         string    `json:"status"`
+	LastRun        *Record   `json:"last_run"`
+	NextExpected   time.Time `json:"next_expected,omitzero"`
+	OverdueSeconds float64   `json:"overdue_seconds"`
+}
+
+var schedulesTemplate = `  <p><a href="{{base}}/">Back to listing</a></p>
+    <h3>Scheduled commands as of {{formatTime .Now}}</h3>
+    <table>
+      <thead>
+        <tr>
+          <th>name</th><th>host_name</th><th>command_name</th><th>schedule</th><th>grace</th><th>last_run</th><th>exit_code</th><th>next_expected</th><th>status</th>
+        </tr>
+      </thead>
+      <tbody>
+{{range .Statuses}}        <tr{{if ne .Status "ok"}} class="failed"{{end}}>
+          <td>{{.Schedule.Name}}</td>
+          <td>{{.Schedule.Host}}</td>
+          <td>{{.Schedule.Command}}</td>
+          <td>{{if .Schedule.Cron}}{{.Schedule.Cron}}{{else}}every {{.Schedule.Interval}}{{end}}</td>
+          <td>{{.Schedule.Grace}}</td>
+          <td>{{with .LastRun}}<a href="{{base}}/commands/{{.ID}}">{{formatTime .StartTime}}</a>{{else}}never{{end}}</td>
+          <td>{{with .LastRun}}{{.ExitCode}}{{end}}</td>
+          <td>{{formatTime .NextExpected}}</td>
+          <td>{{.Status}}</td>
+        </tr>
+{{end}}      </tbody>
+    </table>
+`
+
+func LoadSchedules(path string) ([]Schedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedules []Schedule
+
+	err = json.Unmarshal(data, &schedules)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for i := range schedules {
+		schedule := &schedules[i]
+
+		if schedule.Host == "" || schedule.Command == "" {
+			return nil, fmt.Errorf("schedule %d: host and command must be specified", i)
+		}
+
+		if schedule.Name == "" {
+			schedule.Name = schedule.Host + ": " + schedule.Command
+		}
+
+		switch {
+		case schedule.Cron != "" && schedule.Interval.Duration != 0:
+			return nil, fmt.Errorf("schedule %q: only one of interval or cron may be specified", schedule.Name)
+		case schedule.Cron != "":
+			schedule.cron, err = ParseCron(schedule.Cron)
+			if err != nil {
+				return nil, fmt.Errorf("schedule %q: %w", schedule.Name, err)
+			}
+		case schedule.Interval.Duration <= 0:
+			return nil, fmt.Errorf("schedule %q: a positive interval or cron expression must be specified", schedule.Name)
+		}
+
+		if schedule.Grace.Duration < 0 {
+			return nil, fmt.Errorf("schedule %q: grace period must not be negative", schedule.Name)
+		}
+	}
+
+	return schedules, nil
+}
+
+// next returns the time at which the run following t is expected to start.
+func (s *Schedule) next(t time.Time) time.Time {
+	if s.cron != nil {
+		return s.cron.Next(t)
+	}
+
+	return t.Add(s.Interval.Duration)
+}
+
+func getLastRun(connection *pgx.Conn, tableName string, schedule *Schedule) (*Record, error) {
+	statement := fmt.Sprintf(`SELECT %s FROM %s
+WHERE hostname = $1 AND commandname LIKE $2
+ORDER BY starttime DESC
+LIMIT 1`, recordColumns, tableName)
+
+	var r Record
+	err := connection.QueryRow(context.Background(), statement, schedule.Host, globToLike(schedule.Command)).
+		Scan(&r.ID, &r.StartTime, &r.StopTime, &r.HostName, &r.CommandName, &r.ExitCode)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func scheduleStatus(schedule *Schedule, lastRun *Record, now time.Time) *ScheduleStatus {
+	status := &ScheduleStatus{
+		Schedule: schedule,
+		LastRun:  lastRun,
+	}
+
+	if lastRun == nil {
+		status.Status = ScheduleMissing
+
+		if schedule.cron != nil {
+			status.NextExpected = schedule.next(now)
+		}
+
+		return status
+	}
+
+	status.NextExpected = schedule.next(lastRun.StartTime)
+
+	deadline := status.NextExpected.Add(schedule.Grace.Duration)
+	if now.After(deadline) {
+		status.OverdueSeconds = now.Sub(deadline).Seconds()
+	}
+
+	switch {
+	case now.After(schedule.next(status.NextExpected).Add(schedule.Grace.Duration)):
+		status.Status = ScheduleMissing
+	case now.After(deadline):
+		status.Status = ScheduleLate
+	case lastRun.ExitCode != 0:
+		status.Status = ScheduleFailing
+	default:
+		status.Status = ScheduleOk
+	}
+
+	return status
+}
+
+func GetScheduleStatuses(database *Database, schedules []Schedule) ([]*ScheduleStatus, error) {
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	now := time.Now()
+
+	statuses := make([]*ScheduleStatus, 0, len(schedules))
+
+	for i := range schedules {
+		lastRun, err := getLastRun(connection, database.Table, &schedules[i])
+		if err != nil {
+			return nil, err
+		}
+
+		statuses = append(statuses, scheduleStatus(&schedules[i], lastRun, now))
+	}
+
+	return statuses, nil
+}
+
+func ScheduleMetrics(statuses []*ScheduleStatus) []Metric {
+	status := Metric{
+		Name: "commands_schedule_status",
+		Help: "Current status of each scheduled command.",
+		Type: "gauge",
+	}
+
+	lastRun := Metric{
+		Name: "commands_schedule_last_run_timestamp_seconds",
+		Help: "Start time of the most recent run of each scheduled command.",
+		Type: "gauge",
+	}
+
+	nextExpected := Metric{
+		Name: "commands_schedule_next_expected_timestamp_seconds",
+		Help: "Time at which the next run of each scheduled command is expected to start.",
+		Type: "gauge",
+	}
+
+	overdue := Metric{
+		Name: "commands_schedule_overdue_seconds",
+		Help: "Time elapsed since each scheduled command's grace period expired.",
+		Type: "gauge",
+	}
+
+	for _, s := range statuses {
+		labels := []Label{
+			{"name", s.Schedule.Name},
+			{"host", s.Schedule.Host},
+			{"command", s.Schedule.Command},
+		}
+
+		for _, name := range scheduleStatuses {
+			value := 0.0
+			if s.Status == name {
+				value = 1
+			}
+
+			status.Samples = append(status.Samples, Sample{
+				Labels: append(labels[:len(labels):len(labels)], Label{"status", name}),
+				Value:  value,
+			})
+		}
+
+		if s.LastRun != nil {
+			lastRun.Samples = append(lastRun.Samples, Sample{Labels: labels, Value: float64(s.LastRun.StartTime.Unix())})
+		}
+
+		if !s.NextExpected.IsZero() {
+			nextExpected.Samples = append(nextExpected.Samples, Sample{Labels: labels, Value: float64(s.NextExpected.Unix())})
+		}
+
+		overdue.Samples = append(overdue.Samples, Sample{Labels: labels, Value: s.OverdueSeconds})
+	}
+
+	return []Metric{status, lastRun, nextExpected, overdue}
+}
+
+func ServeSchedules(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		startTime := time.Now()
+
+		statuses, err := GetScheduleStatuses(database, settings().Schedules)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		t, err := template.New("schedules").Funcs(template.FuncMap{
+			"base": templateBase,
+			"formatTime": func(t time.Time) string {
+				if t.IsZero() {
+					return "unknown"
+				}
+
+				return t.Format(detailDate)
+			},
+		}).Parse(schedulesTemplate)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		w.Header().Add("Content-Type", "text/html")
+
+		securityHeaders(w)
+
+		_, err = io.WriteString(w, GeneratePageHeader("Scheduled Commands"))
+		if err != nil {
+			fmt.Println(err)
+
+			return
+		}
+
+		err = t.Execute(w, struct {
+			Now      time.Time
+			Statuses []*ScheduleStatus
+		}{
+			Now:      startTime,
+			Statuses: statuses,
+		})
+		if err != nil {
+			fmt.Println(err)
+
+			return
+		}
+
+		_, err = io.WriteString(w, GeneratePageFooter())
+		if err != nil {
+			fmt.Println(err)
+
+			return
+		}
+
+		fmt.Printf("Constructed HTML page for %d scheduled commands in %v.\n",
+			len(statuses),
+			time.Since(startTime))
+	}
+}
+
+func ServeSchedulesJSON(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		statuses, err := GetScheduleStatuses(database, settings().Schedules)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		serveJSON(w, statuses)
+	}
+}
@@ -0,0 +1,334 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	durationSeconds string = "EXTRACT(EPOCH FROM (stoptime - starttime))"
+	topCount        int    = 10
+)
+
+type Aggregate struct {
+	Key         string  `json:"key,omitempty"`
+	Runs        int     `json:"runs"`
+	Failures    int     `json:"failures"`
+	FailureRate float64 `json:"failure_rate"`
+	P50         float64 `json:"p50_seconds"`
+	P95         float64 `json:"p95_seconds"`
+	Max         float64 `json:"max_seconds"`
+}
+
+type ExitCodeCount struct {
+	ExitCode int `json:"exit_code"`
+	Runs     int `json:"runs"`
+}
+
+type Stats struct {
+	Total           Aggregate       `json:"total"`
+	Hosts           []Aggregate     `json:"hosts"`
+	Commands        []Aggregate     `json:"commands"`
+	ExitCodes       []ExitCodeCount `json:"exit_codes"`
+	BusiestHosts    []Aggregate     `json:"busiest_hosts"`
+	SlowestCommands []Aggregate     `json:"slowest_commands"`
+}
+
+var statsTemplate = `    <h3>{{.Total.Runs}} runs, {{.Total.Failures}} failures ({{percent .Total.FailureRate}}), p50 {{seconds .Total.P50}}, p95 {{seconds .Total.P95}}, max {{seconds .Total.Max}}</h3>
+    <h3>Busiest hosts</h3>
+{{template "aggregates" (link "host_name" .BusiestHosts)}}
+    <h3>Slowest commands</h3>
+{{template "aggregates" (link "command_group" .SlowestCommands)}}
+    <h3>Exit codes</h3>
+    <table>
+      <thead>
+        <tr>
+          <th>exit_code</th><th>runs</th>
+        </tr>
+      </thead>
+      <tbody>
+{{range .ExitCodes}}        <tr{{if ne .ExitCode 0}} class="failed"{{end}}>
+          <td><a href="{{base}}/?exit_code={{.ExitCode}}">{{.ExitCode}}</a></td>
+          <td>{{.Runs}}</td>
+        </tr>
+{{end}}      </tbody>
+    </table>
+    <h3>Hosts</h3>
+{{template "aggregates" (link "host_name" .Hosts)}}
+    <h3>Commands</h3>
+{{template "aggregates" (link "command_group" .Commands)}}
+{{define "aggregates"}}    <table>
+      <thead>
+        <tr>
+          <th>{{.Parameter}}</th><th>runs</th><th>failures</th><th>failure_rate</th><th>p50</th><th>p95</th><th>max</th>
+        </tr>
+      </thead>
+      <tbody>
+{{$parameter := .Parameter}}{{range .Aggregates}}        <tr{{if ne .Failures 0}} class="failed"{{end}}>
+          <td><a href="{{base}}/?{{$parameter}}={{.Key}}">{{.Key}}</a></td>
+          <td>{{.Runs}}</td>
+          <td>{{.Failures}}</td>
+          <td>{{percent .FailureRate}}</td>
+          <td>{{seconds .P50}}</td>
+          <td>{{seconds .P95}}</td>
+          <td>{{seconds .Max}}</td>
+        </tr>
+{{end}}      </tbody>
+    </table>
+{{end}}`
+
+// aggregateColumns computes run, failure and duration statistics, and is
+// supported by both PostgreSQL and CockroachDB.
+var aggregateColumns = fmt.Sprintf(`COUNT(*),
+SUM(CASE WHEN exitcode <> 0 THEN 1 ELSE 0 END)::bigint,
+COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY %[1]s), 0)::float8,
+COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY %[1]s), 0)::float8,
+COALESCE(MAX(%[1]s), 0)::float8`, durationSeconds)
+
+func getAggregates(connection *pgx.Conn, tableName string, parameters *Parameters, key, order string, limit int, s *Settings) ([]Aggregate, error) {
+	conditions := filterConditions(tableName, parameters, s)
+
+	statement := fmt.Sprintf(`SELECT %s AS key,
+%s
+FROM %s%s
+GROUP BY 1
+ORDER BY %s
+LIMIT %d`, key, aggregateColumns, tableName, conditions.Where(), order, limit)
+
+	rows, err := connection.Query(context.Background(), statement, conditions.Args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanAggregates(rows)
+}
+
+// scanAggregates collects rows of a key followed by aggregateColumns.
+func scanAggregates(rows pgx.Rows) ([]Aggregate, error) {
+	aggregates, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Aggregate, error) {
+		var a Aggregate
+
+		err := row.Scan(&a.Key, &a.Runs, &a.Failures, &a.P50, &a.P95, &a.Max)
+
+		return a, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range aggregates {
+		aggregates[i].FailureRate = failureRate(aggregates[i].Runs, aggregates[i].Failures)
+	}
+
+	return aggregates, nil
+}
+
+func getTotalAggregate(connection *pgx.Conn, tableName string, parameters *Parameters, s *Settings) (Aggregate, error) {
+	conditions := filterConditions(tableName, parameters, s)
+
+	statement := fmt.Sprintf("SELECT %s\nFROM %s%s", aggregateColumns, tableName, conditions.Where())
+
+	var a Aggregate
+	err := connection.QueryRow(context.Background(), statement, conditions.Args()...).
+		Scan(&a.Runs, &a.Failures, &a.P50, &a.P95, &a.Max)
+	if err != nil {
+		return a, err
+	}
+
+	a.FailureRate = failureRate(a.Runs, a.Failures)
+
+	return a, nil
+}
+
+func getExitCodeCounts(connection *pgx.Conn, tableName string, parameters *Parameters, s *Settings) ([]ExitCodeCount, error) {
+	conditions := filterConditions(tableName, parameters, s)
+
+	statement := fmt.Sprintf(`SELECT exitcode, COUNT(*)
+FROM %s%s
+GROUP BY exitcode
+ORDER BY COUNT(*) DESC, exitcode`, tableName, conditions.Where())
+
+	rows, err := connection.Query(context.Background(), statement, conditions.Args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (ExitCodeCount, error) {
+		var e ExitCodeCount
+
+		err := row.Scan(&e.ExitCode, &e.Runs)
+
+		return e, err
+	})
+}
+
+func failureRate(runs, failures int) float64 {
+	if runs == 0 {
+		return 0
+	}
+
+	return float64(failures) / float64(runs)
+}
+
+func GetStats(database *Database, parameters *Parameters, s *Settings) (*Stats, []string, error) {
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	stats := &Stats{}
+
+	stats.Total, err = getTotalAggregate(connection, database.Table, parameters, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stats.Hosts, err = getAggregates(connection, database.Table, parameters,
+		"hostname", "key", parameters.CommandCount, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stats.Commands, err = getAggregates(connection, database.Table, parameters,
+		s.CommandGroup, "key", parameters.CommandCount, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stats.BusiestHosts, err = getAggregates(connection, database.Table, parameters,
+		"hostname", "COUNT(*) DESC, key", topCount, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stats.SlowestCommands, err = getAggregates(connection, database.Table, parameters,
+		s.CommandGroup, fmt.Sprintf("MAX(%s) DESC, key", durationSeconds), topCount, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stats.ExitCodes, err = getExitCodeCounts(connection, database.Table, parameters, s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hostNames, err := getHostNames(connection, database.Table)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return stats, hostNames, nil
+}
+
+func ConstructStatsPage(w io.Writer, parameters *Parameters, stats *Stats, hostNames []string) error {
+	t, err := template.New("stats").Funcs(template.FuncMap{
+		"base": templateBase,
+		"percent": func(rate float64) string {
+			return fmt.Sprintf("%.1f%%", rate*100)
+		},
+		"seconds": func(seconds float64) time.Duration {
+			return time.Duration(seconds * float64(time.Second)).Round(time.Millisecond)
+		},
+		"link": func(parameter string, aggregates []Aggregate) any {
+			return struct {
+				Parameter  string
+				Aggregates []Aggregate
+			}{
+				Parameter:  parameter,
+				Aggregates: aggregates,
+			}
+		},
+	}).Parse(statsTemplate)
+	if err != nil {
+		return err
+	}
+
+	var header strings.Builder
+
+	header.WriteString(GeneratePageHeader("Command Statistics"))
+	header.WriteString(generateBackLink())
+	header.WriteString(generateFilterForm("/stats", parameters, hostNames))
+
+	_, err = io.WriteString(w, header.String())
+	if err != nil {
+		return err
+	}
+
+	err = t.Execute(w, stats)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, GeneratePageFooter())
+
+	return err
+}
+
+func ServeStats(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		startTime := time.Now()
+
+		s := settings()
+
+		parameters := parseParameters(r)
+
+		stats, hostNames, err := GetStats(database, parameters, s)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		w.Header().Add("Content-Type", "text/html")
+
+		securityHeaders(w)
+
+		err = ConstructStatsPage(w, parameters, stats, hostNames)
+		if err != nil {
+			fmt.Println(err)
+
+			return
+		}
+
+		fmt.Printf("Constructed statistics page for %d commands in %v.\n",
+			stats.Total.Runs,
+			time.Since(startTime))
+	}
+}
+
+func ServeStatsJSON(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		s := settings()
+
+		stats, _, err := GetStats(database, parseParameters(r), s)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		serveJSON(w, stats)
+	}
+}
@@ -0,0 +1,297 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	streamBatchSize   int           = 1000
+	streamKeepalive   time.Duration = 30 * time.Second
+	streamRetryMillis int           = 5000
+)
+
+var channelInvalidCharacters = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// notifyChannel returns the LISTEN/NOTIFY channel, trigger and function name
+// used for the configured table.
+func notifyChannel(tableName string) string {
+	return "commands_" + channelInvalidCharacters.ReplaceAllString(tableName, "_")
+}
+
+func SetupNotifications(database *Database) error {
+	if databaseType != "postgresql" {
+		return errors.New("LISTEN/NOTIFY is only supported on postgresql")
+	}
+
+	connection, err := openDatabase(database.Url)
+	if err != nil {
+		return err
+	}
+	defer func(connection *pgx.Conn) {
+		err := closeDatabase(connection)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}(connection)
+
+	channel := notifyChannel(database.Table)
+
+	statements := []string{
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION %[1]s() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('%[1]s', NEW.id::text);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql`, channel),
+		fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s", channel, database.Table),
+		fmt.Sprintf("CREATE TRIGGER %[1]s AFTER INSERT ON %[2]s FOR EACH ROW EXECUTE FUNCTION %[1]s()", channel, database.Table),
+	}
+
+	for _, statement := range statements {
+		_, err = connection.Exec(context.Background(), statement)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Installed trigger %s on table %s.\n", channel, database.Table)
+
+	return nil
+}
+
+func getLatestID(connection *pgx.Conn, tableName string) (int64, error) {
+	statement := fmt.Sprintf("SELECT COALESCE(MAX(id), 0) FROM %s", tableName)
+
+	var id int64
+	err := connection.QueryRow(context.Background(), statement).Scan(&id)
+	if err != nil {
+		return id, err
+	}
+
+	return id, nil
+}
+
+// StreamedCommand is a Record as sent to live listings, which also show the
+// command's group and whether its duration is anomalous.
+type StreamedCommand struct {
+	Record
+	CommandGroup string
+	Anomalous    bool
+}
+
+func (c StreamedCommand) MarshalJSON() ([]byte, error) {
+	type record Record
+
+	return json.Marshal(struct {
+		record
+		Duration        string  `json:"duration"`
+		DurationSeconds float64 `json:"duration_seconds"`
+		CommandGroup    string  `json:"command_group"`
+		Anomalous       bool    `json:"anomalous"`
+	}{
+		record:          record(c.Record),
+		Duration:        c.Duration().Truncate(time.Second).String(),
+		DurationSeconds: c.Duration().Seconds(),
+		CommandGroup:    c.CommandGroup,
+		Anomalous:       c.Anomalous,
+	})
+}
+
+func getNewCommands(ctx context.Context, connection *pgx.Conn, tableName string, parameters *Parameters, lastID int64, s *Settings) ([]StreamedCommand, error) {
+	conditions := filterConditions(tableName, parameters, s)
+	conditions.Add("id > $%d", lastID)
+
+	statement := fmt.Sprintf("SELECT %s, %s, %s FROM %s%s\nORDER BY id\nLIMIT %d",
+		recordColumns,
+		s.CommandGroup,
+		anomalyCondition(conditions, tableName, s),
+		tableName,
+		conditions.Where(),
+		streamBatchSize)
+
+	rows, err := connection.Query(ctx, statement, conditions.Args()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (StreamedCommand, error) {
+		var c StreamedCommand
+
+		err := row.Scan(&c.ID, &c.StartTime, &c.StopTime, &c.HostName, &c.CommandName, &c.ExitCode, &c.CommandGroup, &c.Anomalous)
+
+		return c, err
+	})
+}
+
+// waitForCommands blocks until new rows may be available, using LISTEN/NOTIFY
+// where supported and falling back to polling otherwise.
+func waitForCommands(ctx context.Context, connection *pgx.Conn, listening bool, s *Settings) error {
+	if !listening {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.StreamInterval):
+			return nil
+		}
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, streamKeepalive)
+	defer cancel()
+
+	_, err := connection.WaitForNotification(waitCtx)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return nil
+	}
+
+	return err
+}
+
+func writeEvent(w http.ResponseWriter, command StreamedCommand) error {
+	data, err := json.Marshal(command)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: command\ndata: %s\n\n", command.ID, data)
+
+	return err
+}
+
+func ServeStream(database *Database) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		ctx := r.Context()
+
+		s := settings()
+
+		parameters := parseParameters(r)
+
+		rc := http.NewResponseController(w)
+
+		err := rc.SetWriteDeadline(time.Time{})
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		connection, err := openDatabase(database.Url)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+		defer func(connection *pgx.Conn) {
+			err := closeDatabase(connection)
+			if err != nil {
+				fmt.Println(err)
+			}
+		}(connection)
+
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = r.URL.Query().Get("last_event_id")
+		}
+
+		lastID, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil {
+			lastID, err = getLatestID(connection, database.Table)
+			if err != nil {
+				fmt.Println(err)
+
+				ServerError(w, r, nil)
+
+				return
+			}
+		}
+
+		listening := false
+		if databaseType == "postgresql" {
+			_, err = connection.Exec(ctx, "LISTEN "+notifyChannel(database.Table))
+			if err != nil {
+				fmt.Println(err)
+			} else {
+				listening = true
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+
+		securityHeaders(w)
+
+		_, err = fmt.Fprintf(w, "retry: %d\n\n", streamRetryMillis)
+		if err != nil {
+			return
+		}
+
+		if s.Verbose {
+			fmt.Printf("%s | STREAM: Client %s connected after id %d\n",
+				time.Now().Format(logDate),
+				r.RemoteAddr,
+				lastID)
+		}
+
+		for {
+			commands, err := getNewCommands(ctx, connection, database.Table, parameters, lastID, s)
+			if err != nil {
+				if ctx.Err() == nil {
+					fmt.Println(err)
+				}
+
+				return
+			}
+
+			for _, command := range commands {
+				err = writeEvent(w, command)
+				if err != nil {
+					return
+				}
+
+				lastID = command.ID
+			}
+
+			if len(commands) == 0 {
+				_, err = fmt.Fprint(w, ": keepalive\n\n")
+				if err != nil {
+					return
+				}
+			}
+
+			err = rc.Flush()
+			if err != nil {
+				return
+			}
+
+			if len(commands) == streamBatchSize {
+				continue
+			}
+
+			err = waitForCommands(ctx, connection, listening, s)
+			if err != nil {
+				if ctx.Err() == nil {
+					fmt.Println(err)
+				}
+
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,284 @@
+/*
+Copyright © 2026 Seednode <seednode@seedno.de>
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type identityKey struct{}
+
+// ClientIdentity maps the subject of a client certificate, in the RFC 2253
+// form printed by `openssl x509 -noout -subject -nameopt RFC2253`, to the
+// name of a user.
+type ClientIdentity struct {
+	Subject string `json:"subject"`
+	User    string `json:"user"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// intermediateCipherSuites are the TLS 1.2 suites offering both forward
+// secrecy and authenticated encryption. TLS 1.3 suites are not configurable.
+var intermediateCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// CertificateReloader serves the certificate and key from the given files,
+// reloading them whenever either changes on disk.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+}
+
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	reloader := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	err := reloader.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+func (c *CertificateReloader) Reload() error {
+	certificate, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.certificate = &certificate
+	c.mu.Unlock()
+
+	return nil
+}
+
+func (c *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.certificate, nil
+}
+
+// Watch reloads the certificate whenever the files, or the directories
+// containing them, change. Directories are watched rather than the files
+// themselves, so that certificates replaced by renaming or by swapping a
+// symlink, as certbot and Kubernetes do, are still picked up.
+//
+// A certificate which fails to load, such as when only one of the pair has
+// been replaced so far, is logged and the previous one is kept.
+func (c *CertificateReloader) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	files := map[string]bool{
+		filepath.Clean(c.certFile): true,
+		filepath.Clean(c.keyFile):  true,
+	}
+
+	for file := range files {
+		err = watcher.Add(filepath.Dir(file))
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			fmt.Println(err)
+		case event := <-watcher.Events:
+			// Kubernetes updates mounted secrets by swapping a "..data"
+			// symlink, rather than by touching the files themselves.
+			if event.Has(fsnotify.Chmod) || !files[filepath.Clean(event.Name)] && !strings.HasPrefix(filepath.Base(event.Name), "..") {
+				continue
+			}
+
+			err := c.Reload()
+			if err != nil {
+				fmt.Printf("%s | TLS: Keeping previous certificate: %v\n",
+					time.Now().Format(logDate),
+					err)
+
+				continue
+			}
+
+			if settings().Verbose {
+				fmt.Printf("%s | TLS: Reloaded certificate from %s\n",
+					time.Now().Format(logDate),
+					c.certFile)
+			}
+		}
+	}
+}
+
+func LoadClientIdentities(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ClientIdentity
+
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	identities := make(map[string]string, len(entries))
+
+	for i, entry := range entries {
+		if entry.Subject == "" || entry.User == "" {
+			return nil, fmt.Errorf("identity %d: subject and user must be specified", i)
+		}
+
+		identities[entry.Subject] = entry.User
+	}
+
+	return identities, nil
+}
+
+func loadClientCAs(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no certificates found", path)
+	}
+
+	return pool, nil
+}
+
+// TLSConfig builds the server's TLS configuration from the --tls-* flags.
+func TLSConfig(reloader *CertificateReloader) (*tls.Config, error) {
+	minVersion, ok := tlsVersions[tlsMinVersion]
+	if !ok {
+		return nil, fmt.Errorf("invalid minimum TLS version %q", tlsMinVersion)
+	}
+
+	config := &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		MinVersion:     minVersion,
+	}
+
+	switch tlsCipherPolicy {
+	case "default":
+	case "intermediate":
+		config.CipherSuites = intermediateCipherSuites
+	case "modern":
+		config.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("invalid TLS cipher policy %q", tlsCipherPolicy)
+	}
+
+	if tlsClientCA != "" {
+		pool, err := loadClientCAs(tlsClientCA)
+		if err != nil {
+			return nil, err
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// RequestIdentity returns the user a request was authenticated as, if any.
+func RequestIdentity(r *http.Request) string {
+	identity, _ := r.Context().Value(identityKey{}).(string)
+
+	return identity
+}
+
+// clientIdentity maps the verified client certificate presented with the
+// request to a user. Without a mapping, the certificate's common name is used.
+func clientIdentity(r *http.Request, identities map[string]string) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", errors.New("no verified client certificate")
+	}
+
+	subject := r.TLS.VerifiedChains[0][0].Subject
+
+	if identities == nil {
+		return subject.CommonName, nil
+	}
+
+	user, ok := identities[subject.String()]
+	if !ok {
+		return "", fmt.Errorf("no identity mapped for client certificate subject %q", subject.String())
+	}
+
+	return user, nil
+}
+
+// AuthenticateClients rejects requests whose client certificate is not
+// mapped to a user, and otherwise records the user for later authorization.
+// Requests received via Unix sockets, which are served over plain HTTP, are
+// passed through, identified only by a trusted proxy if at all.
+func AuthenticateClients(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := settings()
+
+		if r.TLS == nil {
+			handler.ServeHTTP(w, r)
+
+			return
+		}
+
+		user, err := clientIdentity(r, s.Identities)
+		if err != nil {
+			if s.Verbose {
+				fmt.Printf("%s | TLS: Rejected client %s: %v\n",
+					time.Now().Format(logDate),
+					r.RemoteAddr,
+					err)
+			}
+
+			Forbidden(w)
+
+			return
+		}
+
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, user)))
+	})
+}
@@ -6,65 +6,41 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"html/template"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
-	"reflect"
+	"regexp"
 	"strconv"
-	"text/template"
+	"strings"
 	"time"
 
-	"net/http/pprof"
-
+	"github.com/jackc/pgx/v5"
 	"github.com/julienschmidt/httprouter"
+	"github.com/spf13/cobra"
 )
 
 const (
 	logDate string = `2006-01-02T15:04:05.000-07:00`
 )
 
-var templateFuncs = template.FuncMap{"rangeStruct": RangeStructer}
-
-var htmlTemplate = `{{range .}}        <tr>
-{{range rangeStruct .}}          <td>{{.}}</td>
-{{end}}        </tr>
-{{end}}`
-
-func RangeStructer(args ...any) []any {
-	if len(args) == 0 {
-		return nil
-	}
-
-	v := reflect.ValueOf(args[0])
-	if v.Kind() != reflect.Struct {
-		return nil
-	}
-
-	out := make([]any, v.NumField())
-	for i := range v.NumField() {
-		out[i] = v.Field(i).Interface()
-	}
-
-	return out
-}
+var basePathPattern = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)*$`)
 
-func securityHeaders(w http.ResponseWriter) {
-	w.Header().Set("Cross-Origin-Embedder-Policy", "require-corp")
-	w.Header().Set("Cross-Origin-Opener-Policy", "same-origin")
-	w.Header().Set("Cross-Origin-Resource-Policy", "same-site")
-	w.Header().Set("Permissions-Policy", "geolocation=(), midi=(), sync-xhr=(), microphone=(), camera=(), magnetometer=(), gyroscope=(), fullscreen=(), payment=()")
-	w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-	w.Header().Set("X-Frame-Options", "SAMEORIGIN")
-	w.Header().Set("X-Xss-Protection", "1; mode=block")
-}
+const (
+	defaultCommandCount int    = 1000
+	formTime            string = `2006-01-02T15:04`
+)
 
-func GenerateHeader(commandCount, totalCommandCount, failedCommandCount int) string {
-	htmlHeader := `<html>
-  <style>
+const htmlStyle string = `  <style>
     table {
       border: 2px solid #aaa;
       table-layout: fixed;
@@ -72,6 +48,19 @@ func GenerateHeader(commandCount, totalCommandCount, failedCommandCount int) str
     tr:nth-child(even) {
       background: #f4f4f4;
     }
+    tr.failed, td.failed {
+      background: #fdd;
+    }
+    td.succeeded {
+      background: #dfd;
+    }
+    td.anomalous {
+      background: #fd9;
+      font-weight: bold;
+    }
+    tr.stale {
+      background: #ffe9b3;
+    }
     th,td {
       padding: 0.1em 0.5em;
     }
@@ -84,135 +73,641 @@ func GenerateHeader(commandCount, totalCommandCount, failedCommandCount int) str
       font-weight: bold;
       text-align: center;
     }
+    th a {
+      color: inherit;
+    }
+    form {
+      margin-bottom: 1em;
+    }
   </style>
-  <head>
-    <title>Command History</title>
+`
+
+var timeFormats = []string{formTime, `2006-01-02T15:04:05`, time.RFC3339, time.DateOnly}
+
+var columns = []string{"id", "start_time", "duration", "host_name", "command_name", "command_group", "exit_code"}
+
+var htmlTemplate = `{{range .}}        <tr{{if ne .ExitCode 0}} class="failed"{{end}}>
+          <td><a href="{{base}}/commands/{{.ID}}">{{.ID}}</a></td>
+          <td>{{.StartTime}}</td>
+          <td{{if .Anomalous}} class="anomalous" title="far outside this command's usual duration"{{end}}>{{.Duration}}</td>
+          <td>{{.HostName}}</td>
+          <td>{{.CommandName}}</td>
+          <td><a href="{{base}}/?command_group={{.CommandGroup}}">{{.CommandGroup}}</a></td>
+          <td>{{.ExitCode}}</td>
+        </tr>
+{{end}}`
+
+var liveScript = `    <script>
+      const rows = document.getElementById("rows");
+      const source = new EventSource(rows.dataset.stream);
+      source.addEventListener("command", (event) => {
+        const command = JSON.parse(event.data);
+        const row = document.createElement("tr");
+        if (command.exit_code !== 0) {
+          row.className = "failed";
+        }
+        const cell = (value, href) => {
+          const td = document.createElement("td");
+          if (href) {
+            const link = document.createElement("a");
+            link.href = href;
+            link.textContent = value;
+            td.append(link);
+          } else {
+            td.textContent = value;
+          }
+          row.append(td);
+          return td;
+        };
+        cell(command.id, rows.dataset.base + "/commands/" + command.id);
+        cell(command.start_time);
+        const duration = cell(command.duration);
+        if (command.anomalous) {
+          duration.className = "anomalous";
+          duration.title = "far outside this command's usual duration";
+        }
+        cell(command.host_name);
+        cell(command.command_name);
+        cell(command.command_group, rows.dataset.base + "/?command_group=" + encodeURIComponent(command.command_group));
+        cell(command.exit_code);
+        rows.prepend(row);
+      });
+    </script>
+`
+
+func parseTime(value string) time.Time {
+	for _, format := range timeFormats {
+		t, err := time.ParseInLocation(format, value, time.Local)
+		if err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func parseParameters(r *http.Request) *Parameters {
+	return parseQuery(r.URL.Query())
+}
+
+func parseQuery(query url.Values) *Parameters {
+	commandCount, err := strconv.Atoi(query.Get("count"))
+	if err != nil || commandCount < 1 {
+		commandCount = defaultCommandCount
+	}
+
+	exitCode, err := strconv.Atoi(query.Get("exit_code"))
+	if err != nil {
+		exitCode = -1
+	}
+
+	weekday, err := strconv.Atoi(query.Get("weekday"))
+	if err != nil || weekday < 0 || weekday > 6 {
+		weekday = -1
+	}
+
+	hour, err := strconv.Atoi(query.Get("hour"))
+	if err != nil || hour < 0 || hour > 23 {
+		hour = -1
+	}
+
+	sortBy := query.Get("sort_by")
+	if _, ok := sortColumns[sortBy]; !ok {
+		sortBy = "start_time"
+	}
+
+	sortOrder := query.Get("sort_order")
+	if sortOrder != "asc" {
+		sortOrder = "desc"
+	}
+
+	return &Parameters{
+		CommandCount: commandCount,
+		ExitCode:     exitCode,
+		Failed:       query.Get("failed") == "true",
+		Anomalous:    query.Get("anomalous") == "true",
+		HostName:     query.Get("host_name"),
+		CommandName:  query.Get("command_name"),
+		CommandGroup: query.Get("command_group"),
+		From:         parseTime(query.Get("from")),
+		To:           parseTime(query.Get("to")),
+		Weekday:      weekday,
+		Hour:         hour,
+		SortBy:       sortBy,
+		SortOrder:    sortOrder,
+		Bucket:       query.Get("bucket"),
+		Live:         query.Get("live") == "true",
+	}
+}
+
+func (p *Parameters) Query() url.Values {
+	query := url.Values{}
+
+	if p.CommandCount != defaultCommandCount {
+		query.Set("count", strconv.Itoa(p.CommandCount))
+	}
+
+	if p.ExitCode != -1 {
+		query.Set("exit_code", strconv.Itoa(p.ExitCode))
+	}
+
+	if p.Failed {
+		query.Set("failed", "true")
+	}
+
+	if p.Anomalous {
+		query.Set("anomalous", "true")
+	}
+
+	if p.HostName != "" {
+		query.Set("host_name", p.HostName)
+	}
+
+	if p.CommandName != "" {
+		query.Set("command_name", p.CommandName)
+	}
+
+	if p.CommandGroup != "" {
+		query.Set("command_group", p.CommandGroup)
+	}
+
+	if !p.From.IsZero() {
+		query.Set("from", p.From.Format(formTime))
+	}
+
+	if !p.To.IsZero() {
+		query.Set("to", p.To.Format(formTime))
+	}
+
+	if p.Weekday != -1 {
+		query.Set("weekday", strconv.Itoa(p.Weekday))
+	}
+
+	if p.Hour != -1 {
+		query.Set("hour", strconv.Itoa(p.Hour))
+	}
+
+	if p.SortBy != "start_time" {
+		query.Set("sort_by", p.SortBy)
+	}
+
+	if p.SortOrder != "desc" {
+		query.Set("sort_order", p.SortOrder)
+	}
+
+	if p.Bucket != "" {
+		query.Set("bucket", p.Bucket)
+	}
+
+	if p.Live {
+		query.Set("live", "true")
+	}
+
+	return query
+}
+
+func sortLink(parameters *Parameters, column string) string {
+	query := parameters.Query()
+
+	sortOrder := "desc"
+	if parameters.SortBy == column && parameters.SortOrder == "desc" {
+		sortOrder = "asc"
+	}
+
+	query.Set("sort_by", column)
+	query.Set("sort_order", sortOrder)
+
+	return basePath + "/?" + query.Encode()
+}
+
+func liveLink(parameters *Parameters) string {
+	query := parameters.Query()
+
+	if parameters.Live {
+		query.Del("live")
+	} else {
+		query.Set("live", "true")
+	}
+
+	return basePath + "/?" + query.Encode()
+}
+
+func streamLink(parameters *Parameters) string {
+	query := parameters.Query()
+
+	query.Del("live")
+
+	return basePath + "/api/v1/stream?" + query.Encode()
+}
+
+func formValue(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(formTime)
+}
+
+func generateFilterForm(action string, parameters *Parameters, hostNames []string) string {
+	var form strings.Builder
+
+	action = basePath + action
+
+	form.WriteString(fmt.Sprintf(`
+    <form method="get" action="%s">
+      <label>host <select name="host_name">
+        <option value="">all</option>
+`, action))
+
+	for _, hostName := range hostNames {
+		selected := ""
+		if hostName == parameters.HostName {
+			selected = " selected"
+		}
+
+		form.WriteString(fmt.Sprintf("        <option value=\"%s\"%s>%s</option>\n",
+			html.EscapeString(hostName),
+			selected,
+			html.EscapeString(hostName)))
+	}
+
+	exitCode := ""
+	if parameters.ExitCode != -1 {
+		exitCode = strconv.Itoa(parameters.ExitCode)
+	}
+
+	failed := ""
+	if parameters.Failed {
+		failed = " checked"
+	}
+
+	anomalous := ""
+	if parameters.Anomalous {
+		anomalous = " checked"
+	}
+
+	form.WriteString(fmt.Sprintf(`      </select></label>
+      <label>command <input type="text" name="command_name" value="%s"></label>
+      <label>group <input type="text" name="command_group" value="%s"></label>
+      <label>exit code <input type="number" name="exit_code" value="%s"></label>
+      <label><input type="checkbox" name="failed" value="true"%s> failed only</label>
+      <label><input type="checkbox" name="anomalous" value="true"%s> anomalous only</label>
+      <label>from <input type="datetime-local" name="from" value="%s"></label>
+      <label>to <input type="datetime-local" name="to" value="%s"></label>
+      <label>count <input type="number" name="count" min="1" value="%d"></label>
+      <input type="hidden" name="sort_by" value="%s">
+      <input type="hidden" name="sort_order" value="%s">
+%s      <input type="submit" value="Filter">
+      <a href="%s">Clear</a>
+    </form>
+`,
+		html.EscapeString(parameters.CommandName),
+		html.EscapeString(parameters.CommandGroup),
+		exitCode,
+		failed,
+		anomalous,
+		formValue(parameters.From),
+		formValue(parameters.To),
+		parameters.CommandCount,
+		html.EscapeString(parameters.SortBy),
+		html.EscapeString(parameters.SortOrder),
+		hiddenInputs(parameters),
+		action))
+
+	return form.String()
+}
+
+// generateAdminLinks offers admins the actions which apply to the listing.
+func generateAdminLinks(parameters *Parameters) string {
+	query := filterQuery(parameters)
+
+	rename := basePath + "/admin/rename"
+	if parameters.HostName != "" {
+		rename += "?" + url.Values{"host_name": {parameters.HostName}}.Encode()
+	}
+
+	links := fmt.Sprintf(`<a href="%s">rename a host</a>`, html.EscapeString(rename))
+
+	if len(query) > 0 {
+		links = fmt.Sprintf(`<a href="%s">delete matching commands</a> | `,
+			html.EscapeString(basePath+"/admin/delete?"+query.Encode())) + links
+	}
+
+	return fmt.Sprintf("    <p>Admin: %s</p>\n", links)
+}
+
+func generateBackLink() string {
+	return fmt.Sprintf(`  <p><a href="%s/">Back to listing</a></p>`, basePath)
+}
+
+// hiddenInputs preserves any parameters which are set by links rather than
+// by the filter form itself.
+func hiddenInputs(parameters *Parameters) string {
+	var inputs strings.Builder
+
+	if parameters.Weekday != -1 {
+		inputs.WriteString(fmt.Sprintf("      <input type=\"hidden\" name=\"weekday\" value=\"%d\">\n", parameters.Weekday))
+	}
+
+	if parameters.Hour != -1 {
+		inputs.WriteString(fmt.Sprintf("      <input type=\"hidden\" name=\"hour\" value=\"%d\">\n", parameters.Hour))
+	}
+
+	if parameters.Live {
+		inputs.WriteString("      <input type=\"hidden\" name=\"live\" value=\"true\">\n")
+	}
+
+	return inputs.String()
+}
+
+func generateColumnHeaders(parameters *Parameters) string {
+	var headers strings.Builder
+
+	for _, column := range columns {
+		if _, ok := sortColumns[column]; !ok {
+			headers.WriteString(fmt.Sprintf("<th>%s</th>", column))
+
+			continue
+		}
+
+		indicator := ""
+		if parameters.SortBy == column {
+			indicator = " &darr;"
+			if parameters.SortOrder == "asc" {
+				indicator = " &uarr;"
+			}
+		}
+
+		headers.WriteString(fmt.Sprintf(`<th><a href="%s">%s</a>%s</th>`,
+			html.EscapeString(sortLink(parameters, column)),
+			column,
+			indicator))
+	}
+
+	return headers.String()
+}
+
+func securityHeaders(w http.ResponseWriter) {
+	w.Header().Set("Cross-Origin-Embedder-Policy", "require-corp")
+	w.Header().Set("Cross-Origin-Opener-Policy", "same-origin")
+	w.Header().Set("Cross-Origin-Resource-Policy", "same-site")
+	w.Header().Set("Permissions-Policy", "geolocation=(), midi=(), sync-xhr=(), microphone=(), camera=(), magnetometer=(), gyroscope=(), fullscreen=(), payment=()")
+	w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+	w.Header().Set("X-Xss-Protection", "1; mode=block")
+}
+
+// templateBase is used by templates to prefix links with --base-path.
+func templateBase() string {
+	return basePath
+}
+
+func GeneratePageHeader(title string) string {
+	return fmt.Sprintf(`<html>
+%s  <head>
+    <title>%s</title>
   </head>
   <body>
-  `
+  `, htmlStyle, html.EscapeString(title))
+}
+
+func GenerateHeader(parameters *Parameters, results *Results, admin bool) string {
+	htmlHeader := GeneratePageHeader("Command History")
 
 	htmlHeader += fmt.Sprintf("  <h3>Displaying up to %v out of %v commands, including %v non-zero exit codes.</h3>",
-		strconv.Itoa(commandCount),
-		strconv.Itoa(totalCommandCount),
-		strconv.Itoa(failedCommandCount))
+		strconv.Itoa(parameters.CommandCount),
+		strconv.Itoa(results.TotalCommandCount),
+		strconv.Itoa(results.FailedCommandCount))
 
-	htmlHeader += `
+	htmlHeader += generateFilterForm("/", parameters, results.HostNames)
+
+	htmlHeader += GenerateHistogramChart(results.Histogram, parameters)
+
+	liveStatus := "off"
+	stream := ""
+	if parameters.Live {
+		liveStatus = "on"
+		stream = fmt.Sprintf(` data-stream="%s" data-base="%s"`, html.EscapeString(streamLink(parameters)), html.EscapeString(basePath))
+	}
+
+	if admin {
+		htmlHeader += generateAdminLinks(parameters)
+	}
+
+	htmlHeader += fmt.Sprintf(`    <p>Live updates: <a href="%s">%s</a></p>
     <table>
       <thead>
         <tr>
-          <th>row</th><th>start_time</th><th>duration</th><th>host_name</th><th>command_name</th><th>exit_code</th>
+          %s
         </tr>
       </thead>
-      <tbody>
-`
+      <tbody id="rows"%s>
+`, html.EscapeString(liveLink(parameters)), liveStatus, generateColumnHeaders(parameters), stream)
 
 	return htmlHeader
 }
 
-func GenerateFooter() string {
-	htmlFooter := `      </tbody>
-    </table>
-  </body>
+func GeneratePageFooter() string {
+	return `  </body>
 </html>`
-
-	return htmlFooter
 }
 
-func ConstructPage(w io.Writer, database *Database, parameters *Parameters) error {
-	startTime := time.Now()
+func GenerateFooter(parameters *Parameters) string {
+	htmlFooter := `      </tbody>
+    </table>
+`
 
-	results, totalCommandCount, failedCommandCount, err := RunQuery(database, parameters)
-	if err != nil {
-		return err
+	if parameters.Live {
+		htmlFooter += liveScript
 	}
 
-	t := template.New("t").Funcs(templateFuncs)
-	t, err = t.Parse(htmlTemplate)
+	htmlFooter += GeneratePageFooter()
+
+	return htmlFooter
+}
+
+func ConstructPage(w io.Writer, parameters *Parameters, results *Results, admin bool) error {
+	t, err := template.New("t").Funcs(template.FuncMap{
+		"base": templateBase,
+	}).Parse(htmlTemplate)
 	if err != nil {
 		return err
 	}
 
-	htmlHeader := GenerateHeader(parameters.CommandCount, totalCommandCount, failedCommandCount)
+	htmlHeader := GenerateHeader(parameters, results, admin)
 	_, err = io.WriteString(w, htmlHeader)
 	if err != nil {
 		return err
 	}
 
-	err = t.Execute(w, results)
+	err = t.Execute(w, results.Rows)
 	if err != nil {
 		return err
 	}
 
-	htmlFooter := GenerateFooter()
+	htmlFooter := GenerateFooter(parameters)
 	_, err = io.WriteString(w, htmlFooter)
-	if err != nil {
-		return err
-	}
 
-	fmt.Printf("Constructed HTML page for up to %v commands (%v total, %v failed) in %v.\n",
-		parameters.CommandCount,
-		totalCommandCount,
-		failedCommandCount,
-		time.Since(startTime))
-
-	return nil
+	return err
 }
 
 func ServePageHandler(database *Database) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-		var commandCount int
-		commandCount, err := strconv.Atoi(r.URL.Query().Get("count"))
+		startTime := time.Now()
+
+		s := settings()
+
+		parameters := parseParameters(r)
+
+		_, admin := requestAdmin(r, s)
+
+		connection, err := openDatabase(database.Url)
 		if err != nil {
-			commandCount = 1000
-		}
+			fmt.Println(err)
 
-		var exitCode int
-		exitCode, err = strconv.Atoi(r.URL.Query().Get("exit_code"))
+			ServerError(w, r, nil)
+
+			return
+		}
+		defer func(connection *pgx.Conn) {
+			err := closeDatabase(connection)
+			if err != nil {
+				fmt.Println(err)
+			}
+		}(connection)
+
+		latestID, updated, err := getLatestCommand(connection, database.Table)
 		if err != nil {
-			exitCode = -1
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
 		}
 
-		hostName := r.URL.Query().Get("host_name")
-
-		commandName := r.URL.Query().Get("command_name")
-
-		sortBy := r.URL.Query().Get("sort_by")
-		switch sortBy {
-		case "duration":
-			sortBy = "duration"
-		case "host_name":
-			sortBy = "hostname"
-		case "command_name":
-			sortBy = "commandname"
-		case "exit_code":
-			sortBy = "exitcode"
-		default:
-			sortBy = "starttime"
+		if changed := time.Unix(0, changedAt.Load()); changed.After(updated) {
+			updated = changed
 		}
 
-		sortOrder := r.URL.Query().Get("sort_order")
-		if sortOrder != "asc" {
-			sortOrder = "desc"
+		// Without an end time, the histogram covers the period up to now, so
+		// moves on with each bucket, even if no commands are run.
+		var current time.Time
+		if parameters.To.IsZero() {
+			_, _, bucket := histogramRange(parameters, parameters.Bucket)
+
+			current = truncateTime(startTime, bucket)
+			if current.After(updated) {
+				updated = current
+			}
 		}
 
-		parameters := &Parameters{
-			CommandCount: commandCount,
-			ExitCode:     exitCode,
-			HostName:     hostName,
-			CommandName:  commandName,
-			SortBy:       sortBy,
-			SortOrder:    sortOrder,
+		// Runs are flagged as anomalous by comparing them to baselines which are
+		// recomputed periodically.
+		computed := baselinesComputed(s)
+		if computed.After(updated) {
+			updated = computed
 		}
 
-		w.Header().Add("Content-Type", "text/html")
+		// The listing includes table-wide totals, so any new command may change
+		// it, regardless of whether it matches the current filters. Reloading the
+		// configuration may also change it, such as by regrouping commands.
+		hash := sha256.Sum256(fmt.Appendf(nil, "%d\x00%s\x00%d\x00%d\x00%d\x00%d\x00%t",
+			s.Generation, r.URL.RawQuery, latestID, updated.UnixNano(), current.Unix(), computed.Unix(), admin))
+
+		w.Header().Set("ETag", `"`+hex.EncodeToString(hash[:16])+`"`)
+		if !updated.IsZero() {
+			w.Header().Set("Last-Modified", updated.UTC().Format(http.TimeFormat))
+		}
+		w.Header().Set("Cache-Control", "no-cache")
 
 		securityHeaders(w)
 
-		err = ConstructPage(w, database, parameters)
+		if notModified(r, w.Header().Get("ETag"), updated) {
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		results, err := runQuery(connection, database.Table, parameters, s)
+		if err != nil {
+			fmt.Println(err)
+
+			ServerError(w, r, nil)
+
+			return
+		}
+
+		w.Header().Add("Content-Type", "text/html")
+
+		err = ConstructPage(w, parameters, results, admin)
 		if err != nil {
 			fmt.Println(err)
+
+			return
 		}
+
+		fmt.Printf("Constructed HTML page for up to %v commands (%v total, %v failed) in %v.\n",
+			parameters.CommandCount,
+			results.TotalCommandCount,
+			results.FailedCommandCount,
+			time.Since(startTime))
+	}
+}
+
+// baseURL returns the scheme, host and base path through which the request
+// was made, for use in absolute links.
+func baseURL(r *http.Request) string {
+	protocol := "http"
+	if r.TLS != nil {
+		protocol = "https"
+	}
+
+	// Only present if set by a trusted proxy; see ForwardedHeaders.
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		protocol = proto
+	}
+
+	return protocol + "://" + r.Host + basePath
+}
+
+// notModified reports whether the client's cached copy, as identified by the
+// request's conditional headers, matches the given validators.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for tag := range strings.SplitSeq(match, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == etag || tag == "*" {
+				return true
+			}
+		}
+
+		return false
 	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.IsZero() {
+		return false
+	}
+
+	return !modified.Truncate(time.Second).After(since)
+}
+
+func serveJSON(w http.ResponseWriter, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		fmt.Println(err)
+
+		w.WriteHeader(http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+
+	securityHeaders(w)
+
+	w.Write(append(data, '\n'))
 }
 
 func ServerError(w http.ResponseWriter, r *http.Request, i any) {
@@ -225,6 +720,36 @@ func ServerError(w http.ResponseWriter, r *http.Request, i any) {
 	w.Write([]byte("500 Internal Server Error\n"))
 }
 
+func BadRequest(w http.ResponseWriter) {
+	w.Header().Add("Content-Type", "text/plain")
+
+	securityHeaders(w)
+
+	w.WriteHeader(http.StatusBadRequest)
+
+	w.Write([]byte("400 Bad Request\n"))
+}
+
+func Forbidden(w http.ResponseWriter) {
+	w.Header().Add("Content-Type", "text/plain")
+
+	securityHeaders(w)
+
+	w.WriteHeader(http.StatusForbidden)
+
+	w.Write([]byte("403 Forbidden\n"))
+}
+
+func NotFound(w http.ResponseWriter) {
+	w.Header().Add("Content-Type", "text/plain")
+
+	securityHeaders(w)
+
+	w.WriteHeader(http.StatusNotFound)
+
+	w.Write([]byte("404 Page Not Found\n"))
+}
+
 func ServerErrorHandler() func(http.ResponseWriter, *http.Request, any) {
 	return ServerError
 }
@@ -241,7 +766,7 @@ func ServeVersion() httprouter.Handle {
 	}
 }
 
-func ServePage() error {
+func ServePage(cmd *cobra.Command) error {
 	var err error
 
 	timeZone := os.Getenv("TZ")
@@ -252,27 +777,38 @@ func ServePage() error {
 		}
 	}
 
-	if verbose {
+	if settings().Verbose {
 		fmt.Printf("%s | START: commands v%s\n",
 			time.Now().Format(logDate),
 			ReleaseVersion,
 		)
 	}
 
-	bindHost, err := net.LookupHost(bind)
-	if err != nil {
-		return err
-	}
+	// --bind is unused when --listen is given.
+	if len(listenAddresses) == 0 {
+		bindHost, err := net.LookupHost(bind)
+		if err != nil {
+			return err
+		}
 
-	bindAddr := net.ParseIP(bindHost[0])
-	if bindAddr == nil {
-		return errors.New("invalid bind address provided")
+		if net.ParseIP(bindHost[0]) == nil {
+			return errors.New("invalid bind address provided")
+		}
 	}
 
 	if databaseType != "cockroachdb" && databaseType != "postgresql" {
 		return errors.New("invalid database type specified")
 	}
 
+	if feedAuthority == "" {
+		feedAuthority, err = os.Hostname()
+		if err != nil {
+			return fmt.Errorf("feed authority: %w", err)
+		}
+	}
+
+	feedAuthority = strings.ToLower(feedAuthority)
+
 	databaseURL, err := GetDatabaseURL()
 	if err != nil {
 		return err
@@ -283,49 +819,118 @@ func ServePage() error {
 		Table: databaseTable,
 	}
 
+	go RefreshBaselines(database)
+
+	go NewAlerter(database).Run()
+
+	go WatchConfig(context.Background(), cmd)
+
 	mux := httprouter.New()
 
 	mux.PanicHandler = ServerErrorHandler()
 
-	mux.GET("/", ServePageHandler(database))
+	mux.GET(basePath+"/", ServePageHandler(database))
 
-	mux.GET("/version", ServeVersion())
+	mux.GET(basePath+"/commands/:id", ServeDetailHandler(database))
 
-	if profile {
-		mux.HandlerFunc("GET", "/debug/pprof/", pprof.Index)
-		mux.HandlerFunc("GET", "/debug/pprof/cmdline", pprof.Cmdline)
-		mux.HandlerFunc("GET", "/debug/pprof/profile", pprof.Profile)
-		mux.HandlerFunc("GET", "/debug/pprof/symbol", pprof.Symbol)
-		mux.HandlerFunc("GET", "/debug/pprof/trace", pprof.Trace)
+	mux.GET(basePath+"/feed.atom", ServeFeed(database, "atom"))
+
+	mux.GET(basePath+"/feed.rss", ServeFeed(database, "rss"))
+
+	mux.GET(basePath+"/badge", ServeBadge(database))
+
+	mux.GET(basePath+"/api/v1/stream", ServeStream(database))
+
+	mux.GET(basePath+"/api/v1/histogram", ServeHistogramJSON(database))
+
+	mux.GET(basePath+"/api/v1/baselines", ServeBaselinesJSON(database))
+
+	mux.GET(basePath+"/analysis", ServeAnalysis(database))
+
+	mux.GET(basePath+"/api/v1/analysis", ServeAnalysisJSON(database))
+
+	mux.GET(basePath+"/heatmaps", ServeHeatmaps(database))
+
+	mux.GET(basePath+"/matrix", ServeMatrix(database))
+
+	mux.GET(basePath+"/incidents", ServeIncidents(database))
+
+	mux.GET(basePath+"/api/v1/incidents", ServeIncidentsJSON(database))
+
+	mux.GET(basePath+"/history", ServeHistory(database))
+
+	mux.GET(basePath+"/hosts", ServeHosts(database))
+
+	mux.GET(basePath+"/api/v1/hosts", ServeHostsJSON(database))
+
+	mux.GET(basePath+"/stats", ServeStats(database))
+
+	mux.GET(basePath+"/api/v1/stats", ServeStatsJSON(database))
+
+	mux.GET(basePath+"/schedules", ServeSchedules(database))
+
+	mux.GET(basePath+"/api/v1/schedules", ServeSchedulesJSON(database))
+
+	// With a separate admin listener, metrics are only served there.
+	if adminBind == "" {
+		mux.GET(basePath+"/metrics", ServeMetrics(database))
+	}
+
+	mux.GET(basePath+"/version", ServeVersion())
+
+	mux.POST(basePath+"/commands/:id/delete", AdminOnly(ServeDeleteCommand(database)))
+
+	mux.GET(basePath+"/admin/delete", AdminOnly(ServeDeleteConfirmation(database)))
+
+	mux.POST(basePath+"/admin/delete", AdminOnly(ServeDeleteMatching(database)))
+
+	mux.GET(basePath+"/admin/rename", AdminOnly(ServeRenameForm(database)))
+
+	mux.POST(basePath+"/admin/rename", AdminOnly(ServeRenameHost(database)))
+
+	var handler http.Handler = mux
+
+	if tlsClientCA != "" {
+		handler = AuthenticateClients(handler)
 	}
 
 	srv := &http.Server{
-		Addr:         net.JoinHostPort(bind, strconv.Itoa(int(port))),
-		Handler:      mux,
+		Handler:      ForwardedHeaders(Compress(handler)),
 		IdleTimeout:  10 * time.Minute,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 5 * time.Minute,
 	}
 
 	if tlsKey != "" && tlsCert != "" {
-		fmt.Printf("%s | Listening on %s://%s/\n",
-			time.Now().Format(logDate),
-			scheme,
-			srv.Addr)
+		reloader, err := NewCertificateReloader(tlsCert, tlsKey)
+		if err != nil {
+			return err
+		}
 
-		err = srv.ListenAndServeTLS(tlsCert, tlsKey)
-	} else {
-		fmt.Printf("%s | Listening on %s://%s/\n",
-			time.Now().Format(logDate),
-			scheme,
-			srv.Addr)
+		srv.TLSConfig, err = TLSConfig(reloader)
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			err := reloader.Watch(context.Background())
+			if err != nil {
+				fmt.Println(err)
+			}
+		}()
 
-		err = srv.ListenAndServe()
+		scheme = "https"
 	}
 
-	if !errors.Is(err, http.ErrServerClosed) {
+	listeners, err := Listen()
+	if err != nil {
 		return err
 	}
 
-	return nil
+	var admin *http.Server
+	if adminBind != "" {
+		admin = NewAdminServer(database, srv.TLSConfig)
+	}
+
+	return Serve(srv, listeners, admin)
 }